@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 )
@@ -11,11 +14,192 @@ import (
 type MetricCheck struct {
 	AlertActive bool
 	Limit       *uint64
+
+	// Warning is the lower limit that sends a warning before the (critical) limit is
+	// reached, Min checks alert when the usage goes under the limits instead of over them.
+	// Level is the severity of the active alert. Recover is the level the usage has to get
+	// back to before an active alert recovers, the limits are used when it is omitted.
+	Warning *uint64
+	Recover *uint64
+	Min     bool
+	Level   Severity
+
+	// Value is the last usage that was checked, Changed is when the severity last changed
+	Value   *uint64
+	Changed time.Time
+
+	// Samples and Window are how many consecutive samples and for how long the limit has
+	// to be breached before alerting, or be healthy before recovering. Streak and
+	// StreakStart keep track of the samples that disagree with the alert state.
+	Samples     uint64
+	Window      time.Duration
+	Streak      uint64
+	StreakStart time.Time
+
+	// FlapTransitions is how many changes of severity within FlapWindow make the check
+	// flapping, its alerts are suppressed until the changes within the window drop to half
+	// of that. Transitions has the times of the recent changes.
+	FlapTransitions uint64
+	FlapWindow      time.Duration
+	Transitions     []time.Time
+	Flapping        bool
+}
+
+// NewMetricCheck returns a metric check with the limit and the sustain settings
+func NewMetricCheck(limit *uint64, s CheckSettings) *MetricCheck {
+	return &MetricCheck{
+		Limit:       limit,
+		Warning:     s.Warning,
+		Recover:     s.Recover,
+		AlertActive: false,
+		Samples:     s.Samples,
+		Window:      time.Duration(s.Seconds) * time.Second,
+
+		FlapTransitions: s.FlapTransitions,
+		FlapWindow:      s.FlapWindowDuration(),
+	}
+}
+
+// NewMinMetricCheck returns a metric check that alerts when the usage goes under the limits
+func NewMinMetricCheck(limit *uint64, s CheckSettings) *MetricCheck {
+	c := NewMetricCheck(limit, s)
+	c.Min = true
+	return c
 }
 
 // ToggleAlertActive changes the state of the alert
 func (c *MetricCheck) ToggleAlertActive() {
-	c.AlertActive = !c.AlertActive
+	if c.AlertActive {
+		c.SetSeverity(SeverityOK)
+	} else {
+		c.SetSeverity(SeverityCritical)
+	}
+}
+
+// Severity returns the severity of the alert state, an active alert without a level is
+// critical
+func (c *MetricCheck) Severity() Severity {
+	switch {
+	case !c.AlertActive:
+		return SeverityOK
+	case c.Level == SeverityOK:
+		return SeverityCritical
+	default:
+		return c.Level
+	}
+}
+
+// SetSeverity changes the alert state to the severity
+func (c *MetricCheck) SetSeverity(sev Severity) {
+	c.AlertActive = sev > SeverityOK
+	c.Level = sev
+	c.Streak = 0
+	c.Changed = time.Now()
+}
+
+// breaches returns true if the usage is over the limit, or under it for Min checks
+func (c *MetricCheck) breaches(limit *uint64, u uint64) bool {
+	switch {
+	case limit == nil:
+		return false
+	case c.Min:
+		return u < *limit
+	default:
+		return u > *limit
+	}
+}
+
+// SeverityOf returns the severity of the usage, critical when it breaches the limit and a
+// warning when it only breaches the warning limit. An active alert does not recover until
+// the usage is back past the recover level, until then it is held at its lowest level.
+func (c *MetricCheck) SeverityOf(u uint64) Severity {
+	switch {
+	case c.breaches(c.Limit, u):
+		return SeverityCritical
+	case c.breaches(c.Warning, u):
+		return SeverityWarning
+	case c.Severity() == SeverityOK || !c.breaches(c.Recover, u):
+		return SeverityOK
+	case c.Warning != nil:
+		return SeverityWarning
+	default:
+		return SeverityCritical
+	}
+}
+
+// Sustained takes the severity of the current sample and returns it once it has lasted for
+// the sustain settings of the check. Until then the severity of the current alert state is
+// returned so that nothing changes.
+func (c *MetricCheck) Sustained(sev Severity) Severity {
+	return c.SustainedAt(sev, time.Now())
+}
+
+// SustainedAt is Sustained for a sample taken at the time now
+func (c *MetricCheck) SustainedAt(sev Severity, now time.Time) Severity {
+	if sev == c.Severity() {
+		c.Streak = 0
+		return sev
+	}
+
+	if c.Streak == 0 {
+		c.StreakStart = now
+	}
+	c.Streak++
+
+	if c.Streak < c.Samples || now.Sub(c.StreakStart) < c.Window {
+		return c.Severity()
+	}
+	return sev
+}
+
+// Flap records whether the severity of the check changed and returns whether the check is
+// flapping, and whether that started or stopped with this change
+func (c *MetricCheck) Flap(transition bool) (flapping, changed bool) {
+	return c.FlapAt(transition, time.Now())
+}
+
+// FlapAt is Flap for a sample taken at the time now
+func (c *MetricCheck) FlapAt(transition bool, now time.Time) (flapping, changed bool) {
+	if c.FlapTransitions == 0 {
+		return false, false // flap detection is disabled
+	}
+
+	if transition {
+		c.Transitions = append(c.Transitions, now)
+	}
+
+	recent := []time.Time{}
+	for _, t := range c.Transitions {
+		if now.Sub(t) < c.FlapWindow {
+			recent = append(recent, t)
+		}
+	}
+	c.Transitions = recent
+
+	n := uint64(len(c.Transitions))
+	switch {
+	case !c.Flapping && n >= c.FlapTransitions:
+		c.Flapping = true
+		return true, true
+	case c.Flapping && n <= c.FlapTransitions/2:
+		c.Flapping = false
+		return false, true
+	default:
+		return c.Flapping, false
+	}
+}
+
+// Describe returns the alert message of the check for the usage, desc is the name of the
+// metric
+func (c *MetricCheck) Describe(name, desc string, u uint64) string {
+	s := fmt.Sprintf("%s: %s limit: %d", name, desc, *c.Limit)
+	if c.Warning != nil {
+		s += fmt.Sprintf(", warning: %d", *c.Warning)
+	}
+	if c.Recover != nil {
+		s += fmt.Sprintf(", recover: %d", *c.Recover)
+	}
+	return s + fmt.Sprintf(", current usage: %d", u)
 }
 
 // StaticCheck checks the container for some static thing that is not based on usage
@@ -23,11 +207,13 @@ func (c *MetricCheck) ToggleAlertActive() {
 type StaticCheck struct {
 	AlertActive bool
 	Expected    *bool
+	Changed     time.Time
 }
 
 // ToggleAlertActive changes the state of the alert
 func (c *StaticCheck) ToggleAlertActive() {
 	c.AlertActive = !c.AlertActive
+	c.Changed = time.Now()
 }
 
 // Checker interface has all of the methods necessary to check a container
@@ -42,42 +228,112 @@ type Checker interface {
 // AlertdContainer has the name of the container and the StaticChecks, and MetricChecks
 // which are to be run on the container.
 type AlertdContainer struct {
-	Name     string `json:"name"`
-	Alert    *Alert
-	CPUCheck *MetricCheck
-	MemCheck *MetricCheck
-	PIDCheck *MetricCheck
+	Name            string            `json:"name"`
+	ID              string            `json:"id"`
+	Image           string            `json:"image"`
+	Labels          map[string]string `json:"labels"`
+	Selector        *Container
+	Discovered      bool
+	Alert           *Alert
+	CPUCheck        *MetricCheck
+	MemCheck        *MetricCheck
+	MemUnit         string
+	MemPercentCheck *MetricCheck
+	PIDCheck        *MetricCheck
+	MaxPIDCheck     *MetricCheck
+
+	// rate checks, these are computed between the current and the previous poll
+	NetRxCheck         *MetricCheck
+	NetTxCheck         *MetricCheck
+	NetErrCheck        *MetricCheck
+	BlkioReadCheck     *MetricCheck
+	BlkioWriteCheck    *MetricCheck
+	BlkioReadOpsCheck  *MetricCheck
+	BlkioWriteOpsCheck *MetricCheck
+	PreStats           *types.StatsJSON
+
+	// BlkioOpsMissing is set once it has been logged that docker does not report the block
+	// I/O operation counts of the container (cgroup v2)
+	BlkioOpsMissing bool
 
 	// static checks only below...
 	ExistenceCheck *StaticCheck
 	RunningCheck   *StaticCheck
+	HealthCheck    *StaticCheck
+
+	// InspectCheck and StatsCheck are active while inspecting the container or getting its
+	// stats fails with an error other than the container being unknown
+	InspectCheck *StaticCheck
+	StatsCheck   *StaticCheck
+
+	// AcceptedExitCodes are the exit codes which do not fail the running check, ExitedAccepted
+	// is set while the container is stopped with one of them
+	AcceptedExitCodes []int
+	ExitedAccepted    bool
+
+	// MaxHealthStarting is the number of seconds the container health can be "starting"
+	// before it is treated as unhealthy
+	MaxHealthStarting *uint64
+
+	// RestartCheck alerts when there are more than Limit restarts within RestartWindow, the
+	// rest of the fields keep track of the restarts seen between polls
+	RestartCheck    *MetricCheck
+	RestartWindow   time.Duration
+	Restarts        []time.Time
+	PreRestartCount int
+	PreStartedAt    string
+
+	// LastInspect is the container from the last time the static checks ran, it is nil while
+	// the container is stopped or could not be inspected
+	LastInspect *types.ContainerJSON
+
+	// Unchecked is set on the checkers created for the containers found by selectors and
+	// discovery until their static checks have run, in events mode nothing else runs them
+	Unchecked bool
+
+	// EventOOMKilled and EventSignal are set from the oom and kill events which come before
+	// the die event of a container when running in events mode
+	EventOOMKilled bool
+	EventSignal    string
 }
 
 // CheckMetrics checks everything where the Limit is not 0, there is no return because the
-// checks modify the error in AlertdContainer
-func (c *AlertdContainer) CheckMetrics(s *types.Stats, e error) {
+// checks modify the error in AlertdContainer. The values of the checks without a limit are
+// kept too, for the metrics.
+func (c *AlertdContainer) CheckMetrics(s *types.StatsJSON, e error) {
+	c.CheckError(c.StatsCheck, "stats", e)
 	switch {
 	case e != nil:
-		c.Alert.Add(e, nil, "Received an unknown error", "")
+		return // there are no stats to check
 	default:
-		if c.CPUCheck.Limit != nil {
-			c.CheckCPUUsage(s)
-		}
-		if c.PIDCheck.Limit != nil {
-			c.CheckMinPids(s)
-		}
-		if c.MemCheck.Limit != nil {
-			c.CheckMemory(s)
+		c.CheckCPUUsage(&s.Stats)
+		c.CheckMinPids(&s.Stats)
+		c.CheckMaxPids(&s.Stats)
+		c.CheckMemory(&s.Stats)
+		c.CheckMemoryPercent(&s.Stats)
+		if c.PreStats != nil {
+			c.CheckNetwork(s)
+			c.CheckBlkio(s)
 		}
+		c.PreStats = s
 	}
 }
 
 // CheckStatics will run all of the static checks that are listed for a container.
 func (c *AlertdContainer) CheckStatics(j *types.ContainerJSON, e error) {
+	c.Unchecked = false
+	c.LastInspect = j
+	c.CheckError(c.InspectCheck, "inspect", e)
 	c.CheckExists(e)
 	if j != nil && c.RunningCheck.Expected != nil {
 		c.CheckRunning(j)
 	}
+	if j != nil && c.HealthCheck.Expected != nil && *c.HealthCheck.Expected {
+		c.CheckHealth(j)
+	}
+	if j != nil && c.RestartCheck.Limit != nil {
+		c.CheckRestarts(j)
+	}
 }
 
 // ChecksShouldStop returns whether the checks should stop after the static checks or
@@ -88,8 +344,12 @@ func (c *AlertdContainer) ChecksShouldStop() bool {
 		return true
 	case c.ExistenceCheck.AlertActive:
 		return true
+	case c.InspectCheck.AlertActive:
+		return true
 	case c.RunningCheck.Expected != nil && !*c.RunningCheck.Expected:
 		return true
+	case c.ExitedAccepted:
+		return true // there are no stats to check until the container is started again
 	case c.Alert.ShouldSend():
 		return true
 	default:
@@ -119,8 +379,68 @@ func (c *AlertdContainer) HasBecomeKnown(e error) bool {
 	return c.ExistenceCheck.AlertActive && e == nil
 }
 
+// Transition returns the transition of the check of the container that alert messages are
+// tagged with
+func (c *AlertdContainer) Transition(check string, value, limit *uint64) Transition {
+	return Transition{
+		Container: c.Name,
+		ID:        c.ID,
+		Image:     c.Image,
+		Labels:    c.Labels,
+		Check:     check,
+		Value:     value,
+		Limit:     limit,
+	}
+}
+
+// Retire adds a recovered message for every check of the container with an active alert, it
+// is called when the container stops being monitored so that its alerts are not left open
+func (c *AlertdContainer) Retire() {
+	active := []string{}
+	for k, m := range c.MetricChecks() {
+		if m != nil && m.AlertActive {
+			active = append(active, k)
+		}
+	}
+	for k, s := range c.StaticChecks() {
+		if s != nil && s.AlertActive {
+			active = append(active, k)
+		}
+	}
+	sort.Strings(active)
+
+	for _, k := range active {
+		i := c.Alert.Len()
+		c.Alert.AddSeverity(SeverityOK, ErrNoLongerMonitored, nil, fmt.Sprintf("%s: %s",
+			c.Name, k), ErrNoLongerMonitored.Error())
+		c.Alert.TagFrom(i, c.Transition(k, nil, nil))
+	}
+}
+
+// CheckError alerts when a docker API call for the container fails with an error other than
+// the container being unknown, and recovers once the call succeeds again. The check name
+// pairs the failure and the recovery like it does for the other checks.
+func (c *AlertdContainer) CheckError(s *StaticCheck, check string, e error) {
+	defer c.Alert.TagFrom(c.Alert.Len(), c.Transition(check, nil, nil))
+	switch {
+	case c.HasErrored(e) && !s.AlertActive:
+		c.Alert.Add(e, ErrUnknown, fmt.Sprintf("%s", c.Name), ErrUnknown.Error())
+		s.ToggleAlertActive()
+
+	case c.HasErrored(e):
+		// do nothing, the alert is active
+	case s.AlertActive:
+		c.Alert.AddSeverity(SeverityOK, ErrUnknownRecovered, nil, fmt.Sprintf("%s", c.Name),
+			ErrUnknownRecovered.Error())
+		s.ToggleAlertActive()
+	default:
+		return // nothing is wrong, just keep going
+	}
+}
+
 // CheckExists checks that the container exists, running or not
 func (c *AlertdContainer) CheckExists(e error) {
+	defer c.Alert.TagFrom(c.Alert.Len(), c.Transition("existence", nil, nil))
 	switch {
 	case c.IsUnknown(e) && !c.ExistenceCheck.AlertActive:
 		// if the alert is not active I need to alert and make it active
@@ -130,137 +450,439 @@ func (c *AlertdContainer) CheckExists(e error) {
 	case c.IsUnknown(e) && c.ExistenceCheck.AlertActive:
 		// do nothing
 	case c.HasErrored(e):
-		// if there is some other error besides an existence check error
-		c.Alert.Add(e, ErrUnknown, fmt.Sprintf("%s", c.Name), "")
+		// some other error besides an existence check error, CheckError alerts about it
 
 	case c.HasBecomeKnown(e):
-		c.Alert.Add(ErrExistCheckRecovered, nil, fmt.Sprintf("%s", c.Name), ErrExistCheckRecovered.Error())
+		c.Alert.AddSeverity(SeverityOK, ErrExistCheckRecovered, nil, fmt.Sprintf("%s", c.Name), ErrExistCheckRecovered.Error())
 		c.ExistenceCheck.ToggleAlertActive()
 	default:
 		return // nothing is wrong, just keep going
 	}
 }
 
+// ExitAccepted returns true if the container has stopped with one of the accepted exit
+// codes and was not killed by the OOM killer
+func (c *AlertdContainer) ExitAccepted(j *types.ContainerJSON) bool {
+	if j.State.Running || j.State.OOMKilled {
+		return false
+	}
+	for _, code := range c.AcceptedExitCodes {
+		if code == j.State.ExitCode {
+			return true
+		}
+	}
+	return false
+}
+
 // ShouldAlertRunning returns whether the running state is as expected
 func (c *AlertdContainer) ShouldAlertRunning(j *types.ContainerJSON) bool {
+	if c.ExitAccepted(j) {
+		return false // the container exited the way it was supposed to
+	}
 	// if they are not equal, return true (send alert)
 	return *c.RunningCheck.Expected != j.State.Running
 }
 
+// ExitReason returns why the container stopped, it is empty when the container is running
+func ExitReason(j *types.ContainerJSON) string {
+	if j.State.Running {
+		return ""
+	}
+
+	r := fmt.Sprintf(", exit code: %d, oom killed: %t", j.State.ExitCode, j.State.OOMKilled)
+	if j.State.Error != "" {
+		r += fmt.Sprintf(", error: %s", j.State.Error)
+	}
+	return r
+}
+
 // CheckRunning will check to see if the container is currently running or not
 func (c *AlertdContainer) CheckRunning(j *types.ContainerJSON) {
+	defer c.Alert.TagFrom(c.Alert.Len(), c.Transition("running", nil, nil))
+	c.ExitedAccepted = c.ExitAccepted(j)
+
 	switch {
 	case c.ShouldAlertRunning(j) && !c.RunningCheck.AlertActive:
 		c.Alert.Add(ErrRunningCheckFail, nil, fmt.Sprintf("%s: expected running state: "+
-			"%t, current running state: %t", c.Name, *c.RunningCheck.Expected, j.State.Running),
-			ErrRunningCheckFail.Error())
+			"%t, current running state: %t%s", c.Name, *c.RunningCheck.Expected,
+			j.State.Running, ExitReason(j)), ErrRunningCheckFail.Error())
 
 		c.RunningCheck.ToggleAlertActive()
 
 	case !c.ShouldAlertRunning(j) && c.RunningCheck.AlertActive:
-		c.Alert.Add(ErrRunningCheckRecovered, nil, fmt.Sprintf("%s: expected running state: "+
-			"%t, current running state: %t", c.Name, *c.RunningCheck.Expected, j.State.Running),
+		c.Alert.AddSeverity(SeverityOK, ErrRunningCheckRecovered, nil, fmt.Sprintf("%s: "+
+			"expected running state: %t, current running state: %t%s", c.Name,
+			*c.RunningCheck.Expected, j.State.Running, ExitReason(j)),
 			ErrRunningCheckRecovered.Error())
 
 		c.RunningCheck.ToggleAlertActive()
 	}
 }
 
+// HealthStartingTooLong returns true if the health status has been "starting" for longer
+// than MaxHealthStarting seconds since the container was started
+func (c *AlertdContainer) HealthStartingTooLong(j *types.ContainerJSON) bool {
+	if c.MaxHealthStarting == nil || j.State.Health.Status != types.Starting {
+		return false
+	}
+
+	started, err := time.Parse(time.RFC3339Nano, j.State.StartedAt)
+	if err != nil {
+		return false
+	}
+
+	return time.Since(started) > time.Duration(*c.MaxHealthStarting)*time.Second
+}
+
+// ShouldAlertHealth returns true if the container healthcheck is unhealthy, or has been
+// starting for too long
+func (c *AlertdContainer) ShouldAlertHealth(j *types.ContainerJSON) bool {
+	return j.State.Health.Status == types.Unhealthy || c.HealthStartingTooLong(j)
+}
+
+// LastHealthOutput returns the output of the most recent health probe
+func LastHealthOutput(j *types.ContainerJSON) string {
+	l := j.State.Health.Log
+	if len(l) == 0 || l[len(l)-1] == nil {
+		return ""
+	}
+	return strings.TrimSpace(l[len(l)-1].Output)
+}
+
+// CheckHealth checks the status of the docker HEALTHCHECK of the container, containers
+// without a healthcheck or which are not running are skipped.
+func (c *AlertdContainer) CheckHealth(j *types.ContainerJSON) {
+	if j.State.Health == nil || !j.State.Running {
+		return
+	}
+	defer c.Alert.TagFrom(c.Alert.Len(), c.Transition("health", nil, nil))
+
+	switch {
+	case c.ShouldAlertHealth(j) && !c.HealthCheck.AlertActive:
+		c.Alert.Add(ErrHealthCheckFail, nil, fmt.Sprintf("%s: health status: %s, failing "+
+			"streak: %d, last probe output: %q", c.Name, j.State.Health.Status,
+			j.State.Health.FailingStreak, LastHealthOutput(j)), ErrHealthCheckFail.Error())
+
+		c.HealthCheck.ToggleAlertActive()
+
+	case j.State.Health.Status == types.Healthy && c.HealthCheck.AlertActive:
+		c.Alert.AddSeverity(SeverityOK, ErrHealthCheckRecovered, nil, fmt.Sprintf("%s: "+
+			"health status: %s", c.Name, j.State.Health.Status), ErrHealthCheckRecovered.Error())
+
+		c.HealthCheck.ToggleAlertActive()
+	}
+}
+
+// RecordRestarts compares the restart count and start time against the previous poll and
+// keeps the times of the restarts that happened within the restart window
+func (c *AlertdContainer) RecordRestarts(j *types.ContainerJSON, now time.Time) {
+	if c.PreStartedAt != "" {
+		n := j.RestartCount - c.PreRestartCount
+		if n <= 0 && j.State.StartedAt != c.PreStartedAt {
+			n = 1 // restarted outside of the restart policy (docker restart, etc.)
+		}
+		for i := 0; i < n; i++ {
+			c.Restarts = append(c.Restarts, now)
+		}
+	}
+	c.PreRestartCount = j.RestartCount
+	c.PreStartedAt = j.State.StartedAt
+
+	// drop the restarts which are older than the window
+	recent := []time.Time{}
+	for _, t := range c.Restarts {
+		if now.Sub(t) <= c.RestartWindow {
+			recent = append(recent, t)
+		}
+	}
+	c.Restarts = recent
+}
+
+// UpForWindow returns true if the container is running and has been up for longer than the
+// restart window
+func (c *AlertdContainer) UpForWindow(j *types.ContainerJSON, now time.Time) bool {
+	started, err := time.Parse(time.RFC3339Nano, j.State.StartedAt)
+	if err != nil {
+		return false
+	}
+	return j.State.Running && now.Sub(started) > c.RestartWindow
+}
+
+// CheckRestarts alerts when the container restarted more than the limit within the restart
+// window, it recovers once the container has stayed up for the whole window
+func (c *AlertdContainer) CheckRestarts(j *types.ContainerJSON) {
+	now := time.Now()
+	c.RecordRestarts(j, now)
+
+	n := uint64(len(c.Restarts))
+	defer c.Alert.TagFrom(c.Alert.Len(), c.Transition("maxRestarts", uint64P(n),
+		c.RestartCheck.Limit))
+
+	switch {
+	case n > *c.RestartCheck.Limit && !c.RestartCheck.AlertActive:
+		c.Alert.Add(ErrRestartCheckFail, nil, fmt.Sprintf("%s: max restarts: %d, restarts "+
+			"in the last %s: %d, restart count: %d", c.Name, *c.RestartCheck.Limit,
+			c.RestartWindow, n, j.RestartCount), ErrRestartCheckFail.Error())
+
+		c.RestartCheck.ToggleAlertActive()
+
+	case c.UpForWindow(j, now) && c.RestartCheck.AlertActive:
+		c.Alert.AddSeverity(SeverityOK, ErrRestartCheckRecovered, nil, fmt.Sprintf("%s: "+
+			"container has been up for %s, restart count: %d", c.Name, c.RestartWindow,
+			j.RestartCount), ErrRestartCheckRecovered.Error())
+
+		c.RestartCheck.ToggleAlertActive()
+	}
+}
+
+// CheckTimeouts runs the checks which can change without the container changing, the
+// restart window and the health starting too long, against the last inspected container. In
+// events mode there is no event to run them until the container changes again.
+func (c *AlertdContainer) CheckTimeouts() {
+	j := c.LastInspect
+	if j == nil || j.ContainerJSONBase == nil || j.State == nil {
+		return
+	}
+
+	if c.HealthCheck.Expected != nil && *c.HealthCheck.Expected {
+		c.CheckHealth(j)
+	}
+	if c.RestartCheck.Limit != nil {
+		c.CheckRestarts(j) // the restarts were recorded already, so it only recovers
+	}
+}
+
 // RealCPUUsage calculates the CPU usage based on the ContainerJSON info
 func (c *AlertdContainer) RealCPUUsage(s *types.Stats) uint64 {
 	totalUsage := float64(s.CPUStats.CPUUsage.TotalUsage)
 	preTotalUsage := float64(s.PreCPUStats.CPUUsage.TotalUsage)
 	systemCPUUsage := float64(s.CPUStats.SystemUsage)
 	preSystemCPUUsage := float64(s.PreCPUStats.SystemUsage)
+	if systemCPUUsage <= preSystemCPUUsage {
+		return 0 // there is no previous sample yet
+	}
 
 	u := (totalUsage - preTotalUsage) / (systemCPUUsage - preSystemCPUUsage) * 100
 	return uint64(u)
 }
 
-// ShouldAlertCPU returns true if the limit is breached
-func (c *AlertdContainer) ShouldAlertCPU(u uint64) bool {
-	return u > *c.CPUCheck.Limit
-}
-
 // CheckCPUUsage takes care of sending the alerts if they are needed
 func (c *AlertdContainer) CheckCPUUsage(s *types.Stats) {
+	c.CheckUsage(c.CPUCheck, c.RealCPUUsage(s), ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+}
 
-	u := c.RealCPUUsage(s)
-	a := c.ShouldAlertCPU(u)
+// CheckMinPids uses the min pids setting and check the number of PIDS in the container
+func (c *AlertdContainer) CheckMinPids(s *types.Stats) {
+	c.CheckUsage(c.PIDCheck, s.PidsStats.Current, ErrMinPIDCheckFail, ErrMinPIDCheckRecovered,
+		"minimum PIDs")
+}
 
-	switch {
-	case a && !c.CPUCheck.AlertActive:
-		c.Alert.Add(ErrCPUCheckFail, nil, fmt.Sprintf("%s: CPU limit: %d, current usage: %d",
-			c.Name, c.CPUCheck.Limit, u), ErrCPUCheckFail.Error())
+// MaxPIDSSeverity returns the severity of the maxPID check, the check is critical when the
+// container has reached its pids cgroup limit and can no longer fork.
+func (c *AlertdContainer) MaxPIDSSeverity(s *types.Stats) Severity {
+	if s.PidsStats.Limit > 0 && s.PidsStats.Current >= s.PidsStats.Limit {
+		return SeverityCritical
+	}
+	return c.MaxPIDCheck.SeverityOf(s.PidsStats.Current)
+}
 
-		c.CPUCheck.ToggleAlertActive()
+// CheckMaxPids uses the max pids setting and checks the number of PIDS in the container
+func (c *AlertdContainer) CheckMaxPids(s *types.Stats) {
+	c.MaxPIDCheck.Value = uint64P(s.PidsStats.Current)
+	if c.MaxPIDCheck.Limit == nil {
+		return // the check is disabled
+	}
 
-	case !a && c.CPUCheck.AlertActive:
-		c.Alert.Add(ErrCPUCheckRecovered, nil, fmt.Sprintf("%s: CPU limit: %d, current usage %d",
-			c.Name, c.CPUCheck.Limit, u), ErrCPUCheckRecovered.Error())
+	sev := c.MaxPIDCheck.Sustained(c.MaxPIDSSeverity(s))
+	c.CheckSeverity(c.MaxPIDCheck, sev, ErrMaxPIDCheckFail, ErrMaxPIDCheckRecovered,
+		c.MaxPIDCheck.Describe(c.Name, fmt.Sprintf("maximum PIDs (pids limit: %d)",
+			s.PidsStats.Limit), s.PidsStats.Current))
+}
 
-		c.CPUCheck.ToggleAlertActive()
+// MemUsageNoCache returns the memory usage in bytes minus the page cache, which is the same
+// number that `docker stats` shows.
+func MemUsageNoCache(s *types.Stats) uint64 {
+	u := s.MemoryStats.Usage
+	for _, k := range []string{"total_inactive_file", "inactive_file", "cache"} {
+		if v, ok := s.MemoryStats.Stats[k]; ok && v < u {
+			return u - v
+		}
 	}
+	return u
 }
 
-// ShouldAlertMinPIDS returns true if the minPID check fails
-func (c *AlertdContainer) ShouldAlertMinPIDS(s *types.Stats) bool {
-	return s.PidsStats.Current < *c.PIDCheck.Limit
+// MemUsage returns the memory usage minus the page cache in the unit that maxMem was
+// configured with, the page cache is subtracted the same way for every unit.
+func (c *AlertdContainer) MemUsage(s *types.Stats) uint64 {
+	return MemUsageNoCache(s) / MemUnits[c.MemUnitString()]
 }
 
-// CheckMinPids uses the min pids setting and check the number of PIDS in the container
-// returns true if alerts should be sent, and also returns the amount of running pids.
-func (c *AlertdContainer) CheckMinPids(s *types.Stats) {
-	a := c.ShouldAlertMinPIDS(s)
-	switch {
-	case c.PIDCheck.Limit == nil:
-		// do nothing because the check is disabled
-	case a && !c.PIDCheck.AlertActive:
-		c.Alert.Add(ErrMinPIDCheckFail, nil, fmt.Sprintf("%s: minimum PIDs: %d, current PIDs: %d",
-			c.Name, c.PIDCheck.Limit, s.PidsStats.Current), ErrMinPIDCheckFail.Error())
-
-		c.PIDCheck.ToggleAlertActive()
+// MemUnitString returns the unit that memory usage is reported in
+func (c *AlertdContainer) MemUnitString() string {
+	if c.MemUnit == "" {
+		return MemUnitMB
+	}
+	return c.MemUnit
+}
 
-	case !a && c.PIDCheck.AlertActive:
-		c.Alert.Add(ErrMinPIDCheckRecovered, nil, fmt.Sprintf("%s: minimum PIDs: %d, current PIDs: %d",
-			c.Name, c.PIDCheck.Limit, s.PidsStats.Current), ErrMinPIDCheckRecovered.Error())
+// CheckMemory checks the memory used by the container in the configured unit
+func (c *AlertdContainer) CheckMemory(s *types.Stats) {
+	c.CheckUsage(c.MemCheck, c.MemUsage(s), ErrMemCheckFail, ErrMemCheckRecovered,
+		fmt.Sprintf("Memory (%s)", c.MemUnitString()))
+}
 
-		c.PIDCheck.ToggleAlertActive()
+// MemPercent returns the memory usage (minus page cache) as a percentage of the
+// container's cgroup memory limit
+func MemPercent(s *types.Stats) uint64 {
+	if s.MemoryStats.Limit == 0 {
+		return 0
 	}
+	return uint64(float64(MemUsageNoCache(s)) / float64(s.MemoryStats.Limit) * 100)
 }
 
-// MemUsageMB returns the memory usage in MB
-func (c *AlertdContainer) MemUsageMB(s *types.Stats) uint64 {
-	return s.MemoryStats.Usage / 1000000
+// CheckMemoryPercent checks the memory used by the container as a percentage of its limit
+func (c *AlertdContainer) CheckMemoryPercent(s *types.Stats) {
+	c.CheckUsage(c.MemPercentCheck, MemPercent(s), ErrMemPercentCheckFail,
+		ErrMemPercentCheckRecovered, "Memory %")
 }
 
-// ShouldAlertMemory returns whether the memory limit has been exceeded
-func (c *AlertdContainer) ShouldAlertMemory(s *types.Stats) bool {
-	// Memory level in MB
-	u := c.MemUsageMB(s)
-	return u > *c.MemCheck.Limit
+// CheckUsage alerts when the usage breaches the limits of the check and recovers when it
+// comes back, desc is the name of the metric that is used in the alert message. The value
+// is kept for the metrics when the check is disabled.
+func (c *AlertdContainer) CheckUsage(m *MetricCheck, u uint64, fail, recovered error,
+	desc string) {
+
+	m.Value = uint64P(u)
+	if m.Limit == nil {
+		return // the check is disabled
+	}
+
+	sev := m.Sustained(m.SeverityOf(u))
+	c.CheckSeverity(m, sev, fail, recovered, m.Describe(c.Name, desc, u))
 }
 
-// CheckMemory checks the memory used by the container in MB, returns true if an
-// error should be sent as well as the actual memory usage
-func (c *AlertdContainer) CheckMemory(s *types.Stats) {
+// CheckSeverity sends the alert when the check changes severity, moving between warning
+// and critical sends the fail error as escalated or de-escalated. A flapping check sends a
+// single notice when it starts flapping and another one with its state once it settles.
+func (c *AlertdContainer) CheckSeverity(m *MetricCheck, sev Severity, fail, recovered error,
+	msg string) {
+
+	defer c.Alert.TagFrom(c.Alert.Len(), c.Transition(c.CheckName(m), m.Value, m.Limit))
 
-	u := c.MemUsageMB(s)
-	a := c.ShouldAlertMemory(s)
+	pre := m.Severity()
+	flapping, changed := m.Flap(sev != pre)
+
+	e := fail
+	if sev == SeverityOK {
+		e = recovered
+	}
 
 	switch {
-	case c.MemCheck.Limit == nil:
-		// do nothing because the check is disabled
-	case a && !c.MemCheck.AlertActive:
-		c.Alert.Add(ErrMemCheckFail, nil, fmt.Sprintf("%s: Memory limit: %d, current usage: %d",
-			c.Name, c.MemCheck.Limit, u), ErrMemCheckFail.Error())
+	case changed && flapping:
+		c.Alert.AddSeverity(SeverityWarning, e, ErrCheckFlapping, msg, ErrCheckFlapping.Error())
+	case changed:
+		c.Alert.AddSeverity(sev, e, ErrCheckFlapStopped, msg, e.Error())
+	case flapping || sev == pre:
+		// nothing to send, a flapping check keeps its state without alerting
+	case sev == SeverityOK:
+		c.Alert.AddSeverity(sev, recovered, nil, msg, recovered.Error())
+	case pre == SeverityOK:
+		c.Alert.AddSeverity(sev, fail, nil, msg, fail.Error())
+	case sev > pre:
+		c.Alert.AddSeverity(sev, fail, ErrSeverityEscalated, msg, fail.Error())
+	default:
+		c.Alert.AddSeverity(sev, fail, ErrSeverityDeescalated, msg, fail.Error())
+	}
+
+	if sev != pre {
+		m.SetSeverity(sev)
+	}
+}
+
+// RatePerSecond returns the per second rate of change between two counter values that were
+// sampled d apart. A counter that went backwards (container restarted) has a rate of 0.
+func RatePerSecond(cur, pre uint64, d time.Duration) uint64 {
+	if cur < pre || d <= 0 {
+		return 0
+	}
+	return uint64(float64(cur-pre) / d.Seconds())
+}
+
+// NetworkTotals sums the network counters across all of the container interfaces, errs
+// is the sum of dropped and errored packets in both directions.
+func NetworkTotals(s *types.StatsJSON) (rx, tx, errs uint64) {
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+		errs += n.RxDropped + n.RxErrors + n.TxDropped + n.TxErrors
+	}
+	return rx, tx, errs
+}
+
+// CheckNetwork checks the network throughput (bytes/sec) and the packet drop/error rate
+// (packets/sec) between the previous poll and this one
+func (c *AlertdContainer) CheckNetwork(s *types.StatsJSON) {
+	d := s.Read.Sub(c.PreStats.Read)
+
+	rx, tx, errs := NetworkTotals(s)
+	preRx, preTx, preErrs := NetworkTotals(c.PreStats)
 
-		c.MemCheck.ToggleAlertActive()
+	c.CheckUsage(c.NetRxCheck, RatePerSecond(rx, preRx, d), ErrNetRxCheckFail,
+		ErrNetRxCheckRecovered, "Network rx bytes/sec")
+	c.CheckUsage(c.NetTxCheck, RatePerSecond(tx, preTx, d), ErrNetTxCheckFail,
+		ErrNetTxCheckRecovered, "Network tx bytes/sec")
+	c.CheckUsage(c.NetErrCheck, RatePerSecond(errs, preErrs, d), ErrNetErrCheckFail,
+		ErrNetErrCheckRecovered, "Network dropped/errored packets/sec")
+}
+
+// BlkioTotals sums the read and write values of the blkio entries across all devices
+func BlkioTotals(entries []types.BlkioStatEntry) (read, write uint64) {
+	for _, e := range entries {
+		switch {
+		case strings.EqualFold(e.Op, "read"):
+			read += e.Value
+		case strings.EqualFold(e.Op, "write"):
+			write += e.Value
+		}
+	}
+	return read, write
+}
+
+// CheckBlkio checks the block I/O rates in bytes/sec and operations/sec between the
+// previous poll and this one
+func (c *AlertdContainer) CheckBlkio(s *types.StatsJSON) {
+	d := s.Read.Sub(c.PreStats.Read)
 
-	case !a && c.MemCheck.AlertActive:
-		c.Alert.Add(ErrMemCheckRecovered, nil, fmt.Sprintf("%s: Memory limit: %d, current usage: %d",
-			c.Name, c.MemCheck.Limit, u), ErrMemCheckRecovered.Error())
+	r, w := BlkioTotals(s.BlkioStats.IoServiceBytesRecursive)
+	preR, preW := BlkioTotals(c.PreStats.BlkioStats.IoServiceBytesRecursive)
 
-		c.MemCheck.ToggleAlertActive()
+	c.CheckUsage(c.BlkioReadCheck, RatePerSecond(r, preR, d), ErrBlkioReadCheckFail,
+		ErrBlkioReadCheckRecovered, "Block I/O read bytes/sec")
+	c.CheckUsage(c.BlkioWriteCheck, RatePerSecond(w, preW, d), ErrBlkioWriteCheckFail,
+		ErrBlkioWriteCheckRecovered, "Block I/O write bytes/sec")
+
+	// the operation counts are not reported on cgroup v2, a rate of 0 would never alert and
+	// would recover the active alerts so the checks are skipped instead
+	if len(s.BlkioStats.IoServicedRecursive) == 0 ||
+		len(c.PreStats.BlkioStats.IoServicedRecursive) == 0 {
+
+		c.BlkioReadOpsCheck.Value, c.BlkioWriteOpsCheck.Value = nil, nil
+		if c.BlkioReadOpsCheck.Limit == nil && c.BlkioWriteOpsCheck.Limit == nil {
+			return
+		}
+		if !c.BlkioOpsMissing {
+			log.Printf("%s: the block I/O operation counts are not reported by docker, "+
+				"maxBlkioReadOps and maxBlkioWriteOps are not checked", c.Name)
+			c.BlkioOpsMissing = true
+		}
+		return
 	}
+
+	rOps, wOps := BlkioTotals(s.BlkioStats.IoServicedRecursive)
+	preROps, preWOps := BlkioTotals(c.PreStats.BlkioStats.IoServicedRecursive)
+
+	c.CheckUsage(c.BlkioReadOpsCheck, RatePerSecond(rOps, preROps, d),
+		ErrBlkioReadOpsCheckFail, ErrBlkioReadOpsCheckRecovered, "Block I/O read ops/sec")
+	c.CheckUsage(c.BlkioWriteOpsCheck, RatePerSecond(wOps, preWOps, d),
+		ErrBlkioWriteOpsCheckFail, ErrBlkioWriteOpsCheckRecovered, "Block I/O write ops/sec")
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
 )
 
 var cli *client.Client
@@ -319,6 +320,29 @@ func TestCheckRunning(t *testing.T) {
 				},
 			},
 		},
+		{
+			Name: "test passes running check with accepted exit code",
+			Config: &Conf{
+				Duration:   100,
+				Iterations: 2,
+				Containers: []Container{
+					{
+						Name:              "test",
+						ExpectedRunning:   boolP(true),
+						AcceptedExitCodes: []int{0},
+					},
+				},
+			},
+			ExpectedAlertLen:   0,
+			ExpectedShouldSend: false,
+			Containers: []TestContainer{
+				TestContainer{
+					Name:  "test",
+					Image: stress,
+					CMD:   []string{"echo", "hello world"},
+				},
+			},
+		},
 		{
 			Name: "test recovers running check",
 			Config: &Conf{
@@ -385,6 +409,154 @@ func TestCheckRunning(t *testing.T) {
 	}
 }
 
+func TestChecksShouldStop(t *testing.T) {
+	state := func(running bool, exitCode int) *types.ContainerJSON {
+		return &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{Running: running, ExitCode: exitCode},
+			},
+		}
+	}
+
+	polls := []struct {
+		Name     string
+		JSON     *types.ContainerJSON
+		Expected bool
+	}{
+		{Name: "running", JSON: state(true, 0), Expected: false},
+		{Name: "exited with an accepted code", JSON: state(false, 0), Expected: true},
+		{Name: "started again", JSON: state(true, 0), Expected: false},
+		{Name: "exited with another code", JSON: state(false, 1), Expected: true},
+	}
+
+	c := NewAlertdContainer(Container{ExpectedRunning: boolP(true),
+		AcceptedExitCodes: []int{0}}, "test")
+	for _, p := range polls {
+		c.Alert.Clear()
+		c.CheckRunning(p.JSON)
+		c.Alert.Clear() // only the state of the checks should stop them
+
+		if c.ChecksShouldStop() != p.Expected {
+			t.Errorf("%s: expected should stop: %t", p.Name, p.Expected)
+		}
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	failed := errors.New("Cannot connect to the Docker daemon")
+	polls := []struct {
+		Name     string
+		Err      error
+		Expected []Severity
+	}{
+		{Name: "ok", Err: nil, Expected: []Severity{}},
+		{Name: "failed", Err: failed, Expected: []Severity{SeverityCritical}},
+		{Name: "still failing", Err: failed, Expected: []Severity{}},
+		{Name: "unknown container", Err: errors.New("Error: No such container: test"),
+			Expected: []Severity{SeverityOK}},
+		{Name: "failed again", Err: failed, Expected: []Severity{SeverityCritical}},
+		{Name: "recovered", Err: nil, Expected: []Severity{SeverityOK}},
+	}
+
+	c := NewAlertdContainer(Container{}, "test")
+	p := PagerDuty{RoutingKey: "key"}
+	keys := map[string]string{}
+
+	for _, poll := range polls {
+		c.Alert.Clear()
+		c.CheckError(c.StatsCheck, "stats", poll.Err)
+
+		if c.Alert.Len() != len(poll.Expected) {
+			t.Fatalf("%s: expected %d messages, got: %s", poll.Name, len(poll.Expected),
+				c.Alert.Dump())
+		}
+		for i, sev := range poll.Expected {
+			if c.Alert.SeverityOf(i) != sev || c.Alert.TransitionOf(i).Check != "stats" {
+				t.Errorf("%s: expected a %s message of the stats check, got: %s %+v",
+					poll.Name, sev, c.Alert.SeverityOf(i), c.Alert.TransitionOf(i))
+			}
+		}
+
+		// the failure triggers an incident that the recovery resolves
+		for _, e := range p.Events(c.Alert) {
+			keys[e.EventAction] = e.DedupKey
+		}
+	}
+
+	if keys["trigger"] == "" || keys["trigger"] != keys["resolve"] {
+		t.Errorf("expected the recovery to resolve the incident, got: %v", keys)
+	}
+
+	// a failed inspect stops the checks until it succeeds again
+	c.Alert.Clear()
+	c.CheckStatics(nil, failed)
+	c.Alert.Clear()
+	if !c.ChecksShouldStop() {
+		t.Errorf("expected the checks to stop while inspecting fails")
+	}
+
+	c.CheckStatics(&types.ContainerJSON{}, nil)
+	switch {
+	case c.Alert.Len() != 1 || c.Alert.SeverityOf(0) != SeverityOK:
+		t.Errorf("expected the inspect error to recover, got: %s", c.Alert.Dump())
+	case c.Alert.TransitionOf(0).Check != "inspect":
+		t.Errorf("expected the recovery of the inspect check, got: %+v", c.Alert.TransitionOf(0))
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Now()
+	inspect := func(status string, started time.Time) *types.ContainerJSON {
+		return &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				State: &types.ContainerState{
+					Running:   true,
+					StartedAt: started.Format(time.RFC3339Nano),
+					Health: &types.Health{
+						Status:        status,
+						FailingStreak: 3,
+						Log:           []*types.HealthcheckResult{{Output: "connection refused\n"}},
+					},
+				},
+			},
+		}
+	}
+
+	polls := []struct {
+		Name string
+		JSON *types.ContainerJSON
+		Err  error
+	}{
+		{Name: "healthy", JSON: inspect(types.Healthy, now), Err: nil},
+		{Name: "unhealthy", JSON: inspect(types.Unhealthy, now), Err: ErrHealthCheckFail},
+		{Name: "still unhealthy", JSON: inspect(types.Unhealthy, now), Err: nil},
+		{Name: "recovered", JSON: inspect(types.Healthy, now), Err: ErrHealthCheckRecovered},
+		{Name: "starting", JSON: inspect(types.Starting, now), Err: nil},
+		{Name: "starting too long", JSON: inspect(types.Starting, now.Add(-time.Minute)),
+			Err: ErrHealthCheckFail},
+		{Name: "recovered after starting", JSON: inspect(types.Healthy, now),
+			Err: ErrHealthCheckRecovered},
+	}
+
+	c := NewAlertdContainer(Container{ExpectedHealthy: boolP(true),
+		MaxHealthStarting: uint64P(30)}, "test")
+	for _, p := range polls {
+		c.Alert.Clear()
+		c.CheckHealth(p.JSON)
+
+		switch {
+		case p.Err == nil && c.Alert.Len() > 0:
+			t.Errorf("%s: expected no alert, got: %s", p.Name, c.Alert.Dump())
+		case p.Err != nil && (c.Alert.Len() != 1 || !ErrContainsErr(c.Alert.Messages[0], p.Err)):
+			t.Errorf("%s: expected: %s, got: %s", p.Name, p.Err, c.Alert.Dump())
+		}
+	}
+
+	if LastHealthOutput(polls[1].JSON) != "connection refused" {
+		t.Errorf("expected the last probe output, got: %q", LastHealthOutput(polls[1].JSON))
+	}
+}
+
 func TestCheckCPUUsage(t *testing.T) {
 	tests := []struct {
 		Name               string
@@ -630,6 +802,29 @@ func TestCheckMemory(t *testing.T) {
 	}
 }
 
+func TestMemUsage(t *testing.T) {
+	s := &types.Stats{}
+	s.MemoryStats.Usage = 300 << 20
+	s.MemoryStats.Stats = map[string]uint64{"total_inactive_file": 100 << 20}
+
+	tests := []struct {
+		Unit     string
+		Expected uint64
+	}{
+		{Unit: "", Expected: 209}, // 200 MiB in MB
+		{Unit: MemUnitMB, Expected: 209},
+		{Unit: MemUnitMiB, Expected: 200},
+		{Unit: MemUnitGiB, Expected: 0},
+	}
+
+	for _, test := range tests {
+		c := NewAlertdContainer(Container{MemUnit: test.Unit}, "test")
+		if u := c.MemUsage(s); u != test.Expected {
+			t.Errorf("%q: expected: %d, got: %d", test.Unit, test.Expected, u)
+		}
+	}
+}
+
 func TestCheckPID(t *testing.T) {
 	tests := []struct {
 		Name               string
@@ -760,3 +955,490 @@ func TestCheckPID(t *testing.T) {
 		Teardown(t, test.Containers)
 	}
 }
+
+func TestNetworkRates(t *testing.T) {
+	now := time.Now()
+	pre := &types.StatsJSON{
+		Stats: types.Stats{Read: now},
+		Networks: map[string]types.NetworkStats{
+			"eth0": {RxBytes: 1000, TxBytes: 500, RxDropped: 1},
+			"eth1": {RxBytes: 1000, TxBytes: 500},
+		},
+	}
+	cur := &types.StatsJSON{
+		Stats: types.Stats{Read: now.Add(2 * time.Second)},
+		Networks: map[string]types.NetworkStats{
+			"eth0": {RxBytes: 5000, TxBytes: 500, RxDropped: 3, TxErrors: 2},
+			"eth1": {RxBytes: 1000, TxBytes: 2500},
+		},
+	}
+
+	rx, tx, errs := NetworkTotals(cur)
+	preRx, preTx, preErrs := NetworkTotals(pre)
+	d := cur.Read.Sub(pre.Read)
+
+	tests := []struct {
+		Name     string
+		Got      uint64
+		Expected uint64
+	}{
+		{Name: "rx bytes/sec", Got: RatePerSecond(rx, preRx, d), Expected: 2000},
+		{Name: "tx bytes/sec", Got: RatePerSecond(tx, preTx, d), Expected: 1000},
+		{Name: "errors/sec", Got: RatePerSecond(errs, preErrs, d), Expected: 2},
+		{Name: "counter reset", Got: RatePerSecond(preRx, rx, d), Expected: 0},
+		{Name: "zero duration", Got: RatePerSecond(rx, preRx, 0), Expected: 0},
+	}
+
+	for _, test := range tests {
+		if test.Got != test.Expected {
+			t.Errorf("%s: expected: %d, got: %d", test.Name, test.Expected, test.Got)
+		}
+	}
+}
+
+func TestCheckNetwork(t *testing.T) {
+	now := time.Now()
+	sample := func(after time.Duration, rx uint64) *types.StatsJSON {
+		return &types.StatsJSON{
+			Stats:    types.Stats{Read: now.Add(after)},
+			Networks: map[string]types.NetworkStats{"eth0": {RxBytes: rx}},
+		}
+	}
+
+	samples := []struct {
+		Stats *types.StatsJSON
+		Err   error
+	}{
+		{Stats: sample(0, 1000), Err: nil}, // the first sample has nothing to compare to
+		{Stats: sample(time.Second, 6000), Err: ErrNetRxCheckFail},
+		{Stats: sample(2*time.Second, 6500), Err: ErrNetRxCheckRecovered},
+	}
+
+	c := NewAlertdContainer(Container{MaxNetRx: uint64P(1000)}, "test")
+	for i, s := range samples {
+		c.Alert.Clear()
+		c.CheckMetrics(s.Stats, nil)
+
+		switch {
+		case s.Err == nil && c.Alert.Len() > 0:
+			t.Errorf("sample %d: expected no alert, got: %s", i, c.Alert.Dump())
+		case s.Err != nil && (c.Alert.Len() != 1 || !ErrContainsErr(c.Alert.Messages[0], s.Err)):
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Err, c.Alert.Dump())
+		}
+	}
+}
+
+func TestBlkioTotals(t *testing.T) {
+	entries := []types.BlkioStatEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 100},
+		{Major: 8, Minor: 0, Op: "Write", Value: 200},
+		{Major: 8, Minor: 0, Op: "Sync", Value: 300},
+		{Major: 8, Minor: 16, Op: "read", Value: 1000},
+		{Major: 8, Minor: 16, Op: "write", Value: 2000},
+		{Major: 8, Minor: 16, Op: "Total", Value: 3000},
+	}
+
+	r, w := BlkioTotals(entries)
+	if r != 1100 || w != 2200 {
+		t.Errorf("expected: 1100 read, 2200 write, got: %d read, %d write", r, w)
+	}
+}
+
+func TestCheckBlkio(t *testing.T) {
+	now := time.Now()
+	sample := func(after time.Duration, write, writeOps uint64) *types.StatsJSON {
+		s := &types.StatsJSON{Stats: types.Stats{Read: now.Add(after)}}
+		s.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+			{Op: "Write", Value: write},
+		}
+		if writeOps > 0 {
+			s.BlkioStats.IoServicedRecursive = []types.BlkioStatEntry{
+				{Op: "Write", Value: writeOps},
+			}
+		}
+		return s
+	}
+
+	samples := []struct {
+		Stats *types.StatsJSON
+		Errs  []error
+	}{
+		{Stats: sample(0, 1000, 10), Errs: nil},
+		{Stats: sample(time.Second, 5000, 20), Errs: []error{ErrBlkioWriteCheckFail}},
+		{Stats: sample(2*time.Second, 6000, 200),
+			Errs: []error{ErrBlkioWriteCheckRecovered, ErrBlkioWriteOpsCheckFail}},
+		// no operation counts (cgroup v2) leaves the ops check as it is
+		{Stats: sample(3*time.Second, 7000, 0), Errs: nil},
+		{Stats: sample(4*time.Second, 8000, 0), Errs: nil},
+	}
+
+	c := NewAlertdContainer(Container{MaxBlkioWrite: uint64P(2000),
+		MaxBlkioWriteOps: uint64P(100)}, "test")
+	for i, s := range samples {
+		c.Alert.Clear()
+		c.CheckMetrics(s.Stats, nil)
+
+		if c.Alert.Len() != len(s.Errs) {
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Errs, c.Alert.Dump())
+			continue
+		}
+		for j, e := range s.Errs {
+			if !ErrContainsErr(c.Alert.Messages[j], e) {
+				t.Errorf("sample %d: expected: %s, got: %s", i, e, c.Alert.Messages[j])
+			}
+		}
+	}
+
+	switch {
+	case !c.BlkioWriteOpsCheck.AlertActive:
+		t.Errorf("expected the ops alert to stay active without the operation counts")
+	case !c.BlkioOpsMissing:
+		t.Errorf("expected the missing operation counts to be logged")
+	}
+}
+
+func TestCheckMaxPID(t *testing.T) {
+	tests := []struct {
+		Name               string
+		Config             *Conf
+		ExpectedAlertLen   int
+		ExpectedAlert      error
+		ExpectedShouldSend bool
+		AlertActive        bool
+		Containers         []TestContainer
+	}{
+		{
+			Name: "test passes max PID check",
+			Config: &Conf{
+				Duration:   100,
+				Iterations: 2,
+				Containers: []Container{
+					{
+						MaxProcs:        uint64P(5),
+						ExpectedRunning: boolP(true),
+						Name:            "test",
+					},
+				},
+			},
+			ExpectedAlertLen:   0,
+			ExpectedShouldSend: false,
+			Containers: []TestContainer{
+				TestContainer{
+					Name:  "test",
+					Image: stress,
+					CMD:   []string{"sleep", "100"},
+				},
+			},
+		},
+		{
+			Name: "test fails max PID check",
+			Config: &Conf{
+				Duration:   100,
+				Iterations: 2,
+				Containers: []Container{
+					{
+						MaxProcs:        uint64P(1),
+						ExpectedRunning: boolP(true),
+						Name:            "test",
+					},
+				},
+			},
+			ExpectedAlertLen:   1,
+			ExpectedAlert:      ErrMaxPIDCheckFail,
+			ExpectedShouldSend: true,
+			Containers: []TestContainer{
+				TestContainer{
+					Name:  "test",
+					Image: stress,
+					CMD:   []string{"sleep", "100"},
+				},
+			},
+		},
+		{
+			Name: "test recovers max PID check",
+			Config: &Conf{
+				Duration:   100,
+				Iterations: 2,
+				Containers: []Container{
+					{
+						MaxProcs:        uint64P(5),
+						ExpectedRunning: boolP(true),
+						Name:            "test",
+					},
+				},
+			},
+			ExpectedAlertLen:   1,
+			ExpectedAlert:      ErrMaxPIDCheckRecovered,
+			ExpectedShouldSend: true,
+			AlertActive:        true,
+			Containers: []TestContainer{
+				TestContainer{
+					Name:  "test",
+					Image: stress,
+					CMD:   []string{"sleep", "100"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		Setup(t, test.Name, test.Containers)
+
+		err := test.Containers[0].Exec("sleep", "100")
+		if err != nil {
+			t.Error(err)
+		}
+
+		a := &Alert{Messages: []error{}}
+		cnt := InitCheckers(test.Config)
+
+		if test.AlertActive {
+			cnt[0].MaxPIDCheck.AlertActive = true
+		}
+
+		for i := uint64(0); i < test.Config.Iterations; i++ {
+			CheckContainers(cnt, cli, a)
+
+			if a.Len() != test.ExpectedAlertLen {
+				t.Errorf("alert len %d does not match expected: %d\n", a.Len(), test.ExpectedAlertLen)
+				t.Error(a.Messages)
+			}
+
+			if a.ShouldSend() != test.ExpectedShouldSend {
+				t.Errorf("alert should send: %t does not match expected: %t", a.ShouldSend(), test.ExpectedShouldSend)
+				t.Error(a.Messages)
+			}
+
+			if test.ExpectedAlert != nil {
+				gotErr := CheckHasErr(a.Messages, test.ExpectedAlert)
+				if !gotErr {
+					t.Errorf("expected error message: %s not found in error messages", test.ExpectedAlert.Error())
+					t.Error(a.Messages)
+				}
+			}
+
+			time.Sleep(time.Duration(test.Config.Duration) * time.Millisecond)
+		}
+
+		Teardown(t, test.Containers)
+	}
+}
+
+func TestRecordRestarts(t *testing.T) {
+	c := &AlertdContainer{RestartWindow: time.Minute}
+	now := time.Now()
+
+	polls := []struct {
+		RestartCount int
+		StartedAt    string
+		After        time.Duration
+		Expected     int
+	}{
+		{RestartCount: 0, StartedAt: "a", After: 0, Expected: 0},
+		{RestartCount: 2, StartedAt: "b", After: time.Second, Expected: 2},
+		{RestartCount: 2, StartedAt: "c", After: 2 * time.Second, Expected: 3},
+		{RestartCount: 2, StartedAt: "c", After: 2 * time.Minute, Expected: 0},
+	}
+
+	for i, p := range polls {
+		j := &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				RestartCount: p.RestartCount,
+				State:        &types.ContainerState{StartedAt: p.StartedAt},
+			},
+		}
+
+		c.RecordRestarts(j, now.Add(p.After))
+		if len(c.Restarts) != p.Expected {
+			t.Errorf("poll %d: expected %d restarts, got %d", i, p.Expected, len(c.Restarts))
+		}
+	}
+}
+
+func TestCheckContainerMetricsTimeouts(t *testing.T) {
+	state := func(restarts int, started time.Time, health string) *types.ContainerJSON {
+		j := &types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				RestartCount: restarts,
+				State: &types.ContainerState{
+					Running:   true,
+					StartedAt: started.Format(time.RFC3339Nano),
+				},
+			},
+		}
+		if health != "" {
+			j.State.Health = &types.Health{Status: health}
+		}
+		return j
+	}
+
+	// the start events of the restarts alert, nothing but the metrics loop recovers it
+	c := NewAlertdContainer(Container{MaxRestarts: uint64P(1)}, "web")
+	c.RestartWindow = 50 * time.Millisecond
+	c.CheckStatics(state(0, time.Now().Add(-time.Hour), ""), nil)
+	c.CheckStatics(state(2, time.Now(), ""), nil)
+	if !CheckHasErr(c.Alert.Messages, ErrRestartCheckFail) {
+		t.Fatalf("expected the restarts to alert, got: %s", c.Alert.Dump())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	a := &Alert{Messages: []error{}}
+	CheckContainerMetrics([]AlertdContainer{c}, nil, a)
+	switch {
+	case a.Len() != 1 || !ErrContainsErr(a.Messages[0], ErrRestartCheckRecovered):
+		t.Errorf("expected the restart check to recover, got: %s", a.Dump())
+	case a.TransitionOf(0).Check != "maxRestarts":
+		t.Errorf("expected the recovery of maxRestarts, got: %+v", a.TransitionOf(0))
+	}
+
+	// there is no event while the health is stuck on starting
+	c = NewAlertdContainer(Container{ExpectedHealthy: boolP(true),
+		MaxHealthStarting: uint64P(1)}, "db")
+	c.CheckStatics(state(0, time.Now().Add(-900*time.Millisecond), types.Starting), nil)
+	if c.Alert.ShouldSend() {
+		t.Fatalf("expected the health to still be starting, got: %s", c.Alert.Dump())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	a = &Alert{Messages: []error{}}
+	CheckContainerMetrics([]AlertdContainer{c}, nil, a)
+	if a.Len() != 1 || !ErrContainsErr(a.Messages[0], ErrHealthCheckFail) {
+		t.Errorf("expected the health starting too long to alert, got: %s", a.Dump())
+	}
+}
+
+func TestMetricCheckSustained(t *testing.T) {
+	now := time.Now()
+	samples := []struct {
+		Sample   Severity
+		After    time.Duration
+		Expected Severity
+	}{
+		{Sample: SeverityCritical, After: 0, Expected: SeverityOK},
+		{Sample: SeverityCritical, After: 10 * time.Second, Expected: SeverityOK},       // 2 samples, 10s
+		{Sample: SeverityCritical, After: 20 * time.Second, Expected: SeverityOK},       // 3 samples, 20s
+		{Sample: SeverityCritical, After: 30 * time.Second, Expected: SeverityCritical}, // 4 samples, 30s
+		{Sample: SeverityOK, After: 40 * time.Second, Expected: SeverityCritical},       // recovery streak starts
+		{Sample: SeverityCritical, After: 50 * time.Second, Expected: SeverityCritical}, // streak broken
+		{Sample: SeverityOK, After: 60 * time.Second, Expected: SeverityCritical},       // streak starts again
+		{Sample: SeverityOK, After: 70 * time.Second, Expected: SeverityCritical},       // 2 samples, 10s
+		{Sample: SeverityOK, After: 80 * time.Second, Expected: SeverityCritical},       // 3 samples, 20s
+		{Sample: SeverityOK, After: 90 * time.Second, Expected: SeverityOK},             // 4 samples, 30s
+	}
+
+	c := NewMetricCheck(uint64P(10), CheckSettings{Samples: 3, Seconds: 30})
+	for i, s := range samples {
+		sev := c.SustainedAt(s.Sample, now.Add(s.After))
+		if sev != s.Expected {
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Expected, sev)
+		}
+		if sev != c.Severity() {
+			c.SetSeverity(sev)
+		}
+	}
+}
+
+func TestCheckUsageSeverity(t *testing.T) {
+	samples := []struct {
+		Usage    uint64
+		Expected Severity
+		Err      error
+	}{
+		{Usage: 50, Expected: SeverityOK, Err: nil},
+		{Usage: 75, Expected: SeverityWarning, Err: ErrCPUCheckFail},
+		{Usage: 80, Expected: SeverityWarning, Err: nil},
+		{Usage: 95, Expected: SeverityCritical, Err: ErrSeverityEscalated},
+		{Usage: 75, Expected: SeverityWarning, Err: ErrSeverityDeescalated},
+		{Usage: 50, Expected: SeverityOK, Err: ErrCPUCheckRecovered},
+		{Usage: 95, Expected: SeverityCritical, Err: ErrCPUCheckFail},
+	}
+
+	c := NewAlertdContainer(Container{
+		MaxCPU: uint64P(90),
+		Checks: map[string]CheckSettings{"maxcpu": {Warning: uint64P(70)}},
+	}, "test")
+
+	for i, s := range samples {
+		c.Alert.Clear()
+		c.CheckUsage(c.CPUCheck, s.Usage, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+
+		if c.CPUCheck.Severity() != s.Expected {
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Expected, c.CPUCheck.Severity())
+		}
+
+		switch {
+		case s.Err == nil && c.Alert.Len() > 0:
+			t.Errorf("sample %d: expected no alert, got: %s", i, c.Alert.Dump())
+		case s.Err != nil && (c.Alert.Len() != 1 || !ErrContainsErr(c.Alert.Messages[0], s.Err)):
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Err, c.Alert.Dump())
+		case s.Err != nil && c.Alert.SeverityOf(0) != s.Expected:
+			t.Errorf("sample %d: expected alert severity: %s, got: %s", i, s.Expected,
+				c.Alert.SeverityOf(0))
+		}
+	}
+}
+
+func TestCheckSeverityFlapping(t *testing.T) {
+	samples := []struct {
+		Sample Severity
+		Err    error
+	}{
+		{Sample: SeverityCritical, Err: ErrCPUCheckFail},
+		{Sample: SeverityOK, Err: ErrCPUCheckRecovered},
+		{Sample: SeverityCritical, Err: ErrCPUCheckFail},
+		{Sample: SeverityOK, Err: ErrCheckFlapping}, // 4 transitions
+		{Sample: SeverityCritical, Err: nil},        // suppressed
+		{Sample: SeverityOK, Err: nil},              // suppressed
+	}
+
+	c := NewAlertdContainer(Container{
+		MaxCPU: uint64P(90),
+		Checks: map[string]CheckSettings{"maxCpu": {FlapTransitions: 4}},
+	}, "test")
+
+	for i, s := range samples {
+		c.Alert.Clear()
+		c.CheckSeverity(c.CPUCheck, s.Sample, ErrCPUCheckFail, ErrCPUCheckRecovered, "test")
+
+		switch {
+		case s.Err == nil && c.Alert.Len() > 0:
+			t.Errorf("sample %d: expected no alert, got: %s", i, c.Alert.Dump())
+		case s.Err != nil && (c.Alert.Len() != 1 || !ErrContainsErr(c.Alert.Messages[0], s.Err)):
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Err, c.Alert.Dump())
+		}
+	}
+
+	if c.CPUCheck.Severity() != SeverityOK {
+		t.Errorf("expected the state to be kept while flapping, got: %s", c.CPUCheck.Severity())
+	}
+
+	// the check settles once the transitions are out of the window
+	flapping, changed := c.CPUCheck.FlapAt(false, time.Now().Add(DefaultFlapSeconds*time.Second))
+	if flapping || !changed {
+		t.Errorf("expected the check to stop flapping, flapping: %t, changed: %t", flapping,
+			changed)
+	}
+}
+
+func TestSeverityOfRecover(t *testing.T) {
+	samples := []struct {
+		Usage    uint64
+		Expected Severity
+	}{
+		{Usage: 95, Expected: SeverityCritical},
+		{Usage: 85, Expected: SeverityWarning}, // under the limit, over the warning
+		{Usage: 75, Expected: SeverityWarning}, // held until it gets under the recover level
+		{Usage: 65, Expected: SeverityOK},
+		{Usage: 75, Expected: SeverityOK}, // no alert is active so nothing is held
+	}
+
+	c := NewMetricCheck(uint64P(90), CheckSettings{Warning: uint64P(80),
+		Recover: uint64P(70)})
+	for i, s := range samples {
+		sev := c.SeverityOf(s.Usage)
+		if sev != s.Expected {
+			t.Errorf("sample %d: expected: %s, got: %s", i, s.Expected, sev)
+		}
+		c.SetSeverity(sev)
+	}
+}
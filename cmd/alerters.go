@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"net/smtp"
 	"net/url"
+	"os"
 	"reflect"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -16,6 +20,7 @@ import (
 // Alerter is the interface which will handle alerting via different methods such as email
 // and twitter/slack
 type Alerter interface {
+	Name() string
 	Valid() error
 	Alert(a *Alert) error
 }
@@ -30,12 +35,17 @@ type Email struct {
 	Subject  string
 }
 
+// Name is the name of the alerter that containers use to route their alerts to it
+func (e Email) Name() string {
+	return "email"
+}
+
 // Alert sends an email alert
 func (e Email) Alert(a *Alert) error {
 	// alerts in string form
 	alerts := a.DumpEmail()
 
-	subject := e.Subject + ": "
+	subject := fmt.Sprintf("[%s] %s: ", a.Severity(), e.Subject)
 	for i := range a.SubjectAddendums {
 		// add addendums to the subject
 		subject += fmt.Sprintf("%s ", a.SubjectAddendums[i])
@@ -109,6 +119,11 @@ type Slack struct {
 	WebhookURL string
 }
 
+// Name is the name of the alerter that containers use to route their alerts to it
+func (s Slack) Name() string {
+	return "slack"
+}
+
 // Valid returns an error if slack settings are invalid
 func (s Slack) Valid() error {
 	errString := []string{}
@@ -135,13 +150,9 @@ func (s Slack) Valid() error {
 func (s Slack) Alert(a *Alert) error {
 	alerts := a.Dump()
 
-	json := fmt.Sprintf("{\"text\": \"%s\"}", alerts)
-	body := bytes.NewReader([]byte(json))
-	resp, err := http.Post(s.WebhookURL, "application/json", body)
-	if err != nil {
+	if err := postJSON(s.WebhookURL, map[string]string{"text": alerts}); err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
 	log.Println("sent alert to slack")
 	return nil
@@ -154,6 +165,11 @@ type Pushover struct {
 	APIURL   string
 }
 
+// Name is the name of the alerter that containers use to route their alerts to it
+func (p Pushover) Name() string {
+	return "pushover"
+}
+
 // Valid returns an error if pushover settings are invalid
 func (p Pushover) Valid() error {
 	errString := []string{}
@@ -184,20 +200,998 @@ func (p Pushover) Valid() error {
 	return errors.Wrap(err, "pushover settings validation fail")
 }
 
+// PushoverPriority returns the pushover message priority of the severity, critical alerts
+// are high priority and recoveries are sent quietly
+func PushoverPriority(sev Severity) int {
+	switch sev {
+	case SeverityCritical:
+		return 1
+	case SeverityWarning:
+		return 0
+	default:
+		return -1
+	}
+}
+
 // Alert sends the alert to Pushover API
 func (p Pushover) Alert(a *Alert) error {
 	alerts := a.Dump()
 
-	parsedBody := fmt.Sprintf("token=%s&user=%s&message=%s", p.APIToken, p.UserKey,
-		url.QueryEscape(alerts))
+	parsedBody := fmt.Sprintf("token=%s&user=%s&priority=%d&message=%s", p.APIToken,
+		p.UserKey, PushoverPriority(a.Severity()), url.QueryEscape(alerts))
 	body := bytes.NewBufferString(parsedBody)
 
-	resp, err := http.Post(p.APIURL, "application/x-www-form-urlencoded", body)
+	req, err := http.NewRequest(http.MethodPost, p.APIURL, body)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if err := doRequest(req); err != nil {
+		return err
+	}
 
 	log.Println("sent alert to pushover")
 	return nil
 }
+
+// AlertData is the structured form of an alert that alerters with a payload format of their
+// own are rendered from
+type AlertData struct {
+	Time     time.Time      `json:"time"`
+	Severity Severity       `json:"severity"`
+	Subject  string         `json:"subject"`
+	Messages []AlertMessage `json:"messages"`
+}
+
+// AlertMessage is a single message of an alert with the check change it was sent for
+type AlertMessage struct {
+	Container string   `json:"container"`
+	ID        string   `json:"id,omitempty"`
+	Image     string   `json:"image,omitempty"`
+	Check     string   `json:"check"`
+	Severity  Severity `json:"severity"`
+	Value     *uint64  `json:"value,omitempty"`
+	Limit     *uint64  `json:"limit,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// Data returns the structured form of the alert
+func (a *Alert) Data(now time.Time) AlertData {
+	d := AlertData{
+		Time:     now,
+		Severity: a.Severity(),
+		Subject:  strings.Join(a.SubjectAddendums, " "),
+		Messages: []AlertMessage{},
+	}
+
+	for i, msg := range a.Messages {
+		t := a.TransitionOf(i)
+		d.Messages = append(d.Messages, AlertMessage{
+			Container: t.Container,
+			ID:        t.ID,
+			Image:     t.Image,
+			Check:     t.Check,
+			Severity:  a.SeverityOf(i),
+			Value:     t.Value,
+			Limit:     t.Limit,
+			Message:   msg.Error(),
+		})
+	}
+	return d
+}
+
+// Webhook sends the alerts to any HTTP endpoint, the body is rendered from the AlertData
+// with the Go text/template Template, or is the AlertData as JSON when there is no template.
+// The webhooks in the webhooks list have an ID, which tells them apart in the routes and the
+// history.
+type Webhook struct {
+	ID       string
+	URL      string
+	Method   string
+	Headers  map[string]string
+	Template string
+}
+
+// webhookFuncs are the functions available to the webhook templates, json quotes a value so
+// that messages can be put in a JSON payload safely
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it, it is
+// "webhook:<id>" for the webhooks with an ID
+func (w Webhook) Name() string {
+	if w.ID != "" {
+		return "webhook:" + w.ID
+	}
+	return "webhook"
+}
+
+// Valid returns an error if the webhook settings are invalid
+func (w Webhook) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Webhook{}, w) {
+		return nil // assume that the webhook was omitted
+	}
+
+	if w.URL == "" {
+		errString = append(errString, ErrWebhookURL.Error())
+	}
+
+	switch strings.ToUpper(w.Method) {
+	case "", http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		errString = append(errString, ErrWebhookMethod.Error())
+	}
+
+	if _, err := template.New("webhook").Funcs(webhookFuncs).Parse(w.Template); err != nil {
+		errString = append(errString, errors.Wrap(ErrWebhookTemplate, err.Error()).Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "webhook settings validation fail")
+}
+
+// Body returns the body of the webhook request for the alert
+func (w Webhook) Body(a *Alert, now time.Time) ([]byte, error) {
+	data := a.Data(now)
+	if w.Template == "" {
+		return json.Marshal(data)
+	}
+
+	t, err := template.New("webhook").Funcs(webhookFuncs).Parse(w.Template)
+	if err != nil {
+		return nil, errors.Wrap(ErrWebhookTemplate, err.Error())
+	}
+
+	b := &bytes.Buffer{}
+	if err := t.Execute(b, data); err != nil {
+		return nil, errors.Wrap(err, "rendering webhook template")
+	}
+	return b.Bytes(), nil
+}
+
+// Alert sends the alert to the webhook
+func (w Webhook) Alert(a *Alert) error {
+	body, err := w.Body(a, time.Now())
+	if err != nil {
+		return err
+	}
+
+	method := strings.ToUpper(w.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(method, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if err := doRequest(req); err != nil {
+		return errors.Wrap(err, "error sending webhook")
+	}
+
+	log.Println("sent alert to webhook")
+	return nil
+}
+
+// postJSON posts the value as JSON to the url
+func postJSON(u string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return doRequest(req)
+}
+
+// AlertTimeout is how long the alerters that use alertClient wait for a response
+const AlertTimeout = 10 * time.Second
+
+// alertClient sends the requests of the HTTP alerters, the timeout keeps an endpoint that
+// does not answer from holding up the history records of the alert forever
+var alertClient = &http.Client{Timeout: AlertTimeout}
+
+// doRequest sends the request and returns an error when the response is not a 2xx status
+func doRequest(req *http.Request) error {
+	resp, err := alertClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty sends every alert message as a PagerDuty Events API v2 event. Failures trigger
+// an incident and recoveries resolve it, the dedup key of the container and check pairs them.
+type PagerDuty struct {
+	RoutingKey string
+	URL        string
+}
+
+// PagerDutyEvent is an event of the PagerDuty Events API v2, the payload is only sent for
+// trigger events
+type PagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *PagerDutyPayload `json:"payload,omitempty"`
+}
+
+// PagerDutyPayload describes the incident of a trigger event
+type PagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Component     string            `json:"component,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]uint64 `json:"custom_details,omitempty"`
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (p PagerDuty) Name() string {
+	return "pagerduty"
+}
+
+// Valid returns an error if pagerduty settings are invalid
+func (p PagerDuty) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(PagerDuty{}, p) {
+		return nil // assume that pagerduty was omitted
+	}
+
+	if p.RoutingKey == "" {
+		errString = append(errString, ErrPagerDutyRoutingKey.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "pagerduty settings validation fail")
+}
+
+// PagerDutySeverity returns the pagerduty event severity of the alert severity
+func PagerDutySeverity(sev Severity) string {
+	switch sev {
+	case SeverityWarning:
+		return "warning"
+	case SeverityOK:
+		return "info"
+	default:
+		return "critical"
+	}
+}
+
+// DedupKey returns the key that pairs the alerts of a check, so that the recovery of the
+// check resolves the incident its failure triggered. Messages which are not from a check
+// are keyed by the message.
+func DedupKey(t Transition, msg error) string {
+	if t.Container == "" && t.Check == "" {
+		return "docker-alertd/" + msg.Error()
+	}
+	return fmt.Sprintf("docker-alertd/%s/%s", t.Container, t.Check)
+}
+
+// Events returns the pagerduty events of the alert messages
+func (p PagerDuty) Events(a *Alert) []PagerDutyEvent {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "docker-alertd"
+	}
+
+	events := []PagerDutyEvent{}
+	for i, msg := range a.Messages {
+		t := a.TransitionOf(i)
+		e := PagerDutyEvent{
+			RoutingKey:  p.RoutingKey,
+			EventAction: "resolve",
+			DedupKey:    DedupKey(t, msg),
+		}
+
+		if sev := a.SeverityOf(i); sev != SeverityOK {
+			summary := msg.Error()
+			if len(summary) > 1024 {
+				summary = summary[:1024] // the longest summary pagerduty accepts
+			}
+
+			e.EventAction = "trigger"
+			e.Payload = &PagerDutyPayload{
+				Summary:       summary,
+				Source:        host,
+				Severity:      PagerDutySeverity(sev),
+				Component:     t.Container,
+				Class:         t.Check,
+				CustomDetails: map[string]uint64{},
+			}
+			if t.Value != nil {
+				e.Payload.CustomDetails["value"] = *t.Value
+			}
+			if t.Limit != nil {
+				e.Payload.CustomDetails["limit"] = *t.Limit
+			}
+		}
+
+		events = append(events, e)
+	}
+	return events
+}
+
+// Alert sends an event to pagerduty for every alert message
+func (p PagerDuty) Alert(a *Alert) error {
+	u := p.URL
+	if u == "" {
+		u = PagerDutyEventsURL
+	}
+
+	errString := []string{}
+	for _, e := range p.Events(a) {
+		if err := postJSON(u, e); err != nil {
+			errString = append(errString, fmt.Sprintf("%s %s: %s", e.EventAction, e.DedupKey, err))
+		}
+	}
+
+	if len(errString) > 0 {
+		return errors.Wrap(errors.New(strings.Join(errString, ", ")),
+			"error sending pagerduty events")
+	}
+
+	log.Println("sent alert to pagerduty")
+	return nil
+}
+
+// OpsgenieURL is the Opsgenie API, accounts in the EU use https://api.eu.opsgenie.com
+const OpsgenieURL = "https://api.opsgenie.com"
+
+// Opsgenie creates an Opsgenie alert for every failing check and closes it when the check
+// recovers, the alias of the container and check pairs them
+type Opsgenie struct {
+	APIKey string
+	URL    string
+	Tags   []string
+}
+
+// OpsgenieAlert is the body of the Opsgenie create alert request
+type OpsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details"`
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (o Opsgenie) Name() string {
+	return "opsgenie"
+}
+
+// Valid returns an error if opsgenie settings are invalid
+func (o Opsgenie) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Opsgenie{}, o) {
+		return nil // assume that opsgenie was omitted
+	}
+
+	if o.APIKey == "" {
+		errString = append(errString, ErrOpsgenieAPIKey.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "opsgenie settings validation fail")
+}
+
+// OpsgeniePriority returns the opsgenie alert priority of the severity
+func OpsgeniePriority(sev Severity) string {
+	switch sev {
+	case SeverityCritical:
+		return "P1"
+	case SeverityWarning:
+		return "P3"
+	default:
+		return "P5"
+	}
+}
+
+// OpsgenieDetails returns the details of the container and check attached to the alert
+func OpsgenieDetails(t Transition) map[string]string {
+	d := map[string]string{}
+	for k, v := range map[string]string{
+		"container": t.Container,
+		"id":        t.ID,
+		"image":     t.Image,
+		"check":     t.Check,
+	} {
+		if v != "" {
+			d[k] = v
+		}
+	}
+
+	if t.Value != nil {
+		d["value"] = fmt.Sprint(*t.Value)
+	}
+	if t.Limit != nil {
+		d["limit"] = fmt.Sprint(*t.Limit)
+	}
+	return d
+}
+
+// Requests returns the opsgenie requests of the alert messages, failures create an alert
+// and recoveries close it
+func (o Opsgenie) Requests(a *Alert) ([]*http.Request, error) {
+	u := strings.TrimSuffix(o.URL, "/")
+	if u == "" {
+		u = OpsgenieURL
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "docker-alertd"
+	}
+
+	reqs := []*http.Request{}
+	for i, msg := range a.Messages {
+		t := a.TransitionOf(i)
+		alias := DedupKey(t, msg)
+
+		var endpoint string
+		var body interface{}
+		switch sev := a.SeverityOf(i); sev {
+		case SeverityOK:
+			endpoint = fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", u,
+				url.PathEscape(alias))
+			body = map[string]string{"source": host, "note": msg.Error()}
+		default:
+			message := msg.Error()
+			if len(message) > 130 {
+				message = message[:130] // the longest message opsgenie accepts
+			}
+
+			endpoint = u + "/v2/alerts"
+			body = OpsgenieAlert{
+				Message:     message,
+				Alias:       alias,
+				Description: msg.Error(),
+				Priority:    OpsgeniePriority(sev),
+				Source:      host,
+				Entity:      t.Container,
+				Tags:        o.Tags,
+				Details:     OpsgenieDetails(t),
+			}
+		}
+
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.Wrap(err, "opsgenie request")
+		}
+
+		req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrap(err, "opsgenie request")
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "GenieKey "+o.APIKey)
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// Alert creates and closes the opsgenie alerts of the alert messages
+func (o Opsgenie) Alert(a *Alert) error {
+	reqs, err := o.Requests(a)
+	if err != nil {
+		return err
+	}
+
+	errString := []string{}
+	for _, req := range reqs {
+		if err := doRequest(req); err != nil {
+			errString = append(errString, fmt.Sprintf("%s: %s", req.URL.Path, err))
+		}
+	}
+
+	if len(errString) > 0 {
+		return errors.Wrap(errors.New(strings.Join(errString, ", ")),
+			"error sending opsgenie alerts")
+	}
+
+	log.Println("sent alert to opsgenie")
+	return nil
+}
+
+// CardField is a labelled value shown on the chat cards
+type CardField struct {
+	Title string
+	Value string
+}
+
+// CardTitle returns the title of the card of an alert message
+func CardTitle(sev Severity, t Transition) string {
+	if t.Container == "" {
+		return fmt.Sprintf("[%s] docker-alertd", sev)
+	}
+	return strings.TrimSpace(fmt.Sprintf("[%s] %s %s", sev, t.Container, t.Check))
+}
+
+// CardFields returns the fields of the container and check shown on the card of an alert
+// message, the fields which are not set are left out
+func CardFields(t Transition) []CardField {
+	fields := []CardField{}
+	for _, f := range []CardField{
+		{"Container", t.Container},
+		{"Check", t.Check},
+	} {
+		if f.Value != "" {
+			fields = append(fields, f)
+		}
+	}
+
+	if t.Value != nil {
+		fields = append(fields, CardField{"Value", fmt.Sprint(*t.Value)})
+	}
+	if t.Limit != nil {
+		fields = append(fields, CardField{"Limit", fmt.Sprint(*t.Limit)})
+	}
+	return fields
+}
+
+// sendCards posts a card for every alert message to the url, card returns the payload of
+// the card of the message at index i
+func sendCards(a *Alert, u string, card func(i int) interface{}) error {
+	errString := []string{}
+	for i := range a.Messages {
+		if err := postJSON(u, card(i)); err != nil {
+			errString = append(errString, err.Error())
+		}
+	}
+
+	if len(errString) > 0 {
+		return errors.New(strings.Join(errString, ", "))
+	}
+	return nil
+}
+
+// Teams posts an Adaptive Card for every alert message to a Microsoft Teams channel through
+// an incoming webhook or a workflow webhook
+type Teams struct {
+	WebhookURL string
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (t Teams) Name() string {
+	return "teams"
+}
+
+// Valid returns an error if teams settings are invalid
+func (t Teams) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Teams{}, t) {
+		return nil // assume that teams was omitted
+	}
+
+	if t.WebhookURL == "" {
+		errString = append(errString, ErrTeamsWebhookURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "teams settings validation fail")
+}
+
+// TeamsColor returns the adaptive card color of the severity
+func TeamsColor(sev Severity) string {
+	switch sev {
+	case SeverityCritical:
+		return "Attention"
+	case SeverityWarning:
+		return "Warning"
+	default:
+		return "Good"
+	}
+}
+
+// Card returns the teams message with the adaptive card of the alert message at index i
+func (t Teams) Card(a *Alert, i int) interface{} {
+	type m map[string]interface{}
+
+	tr := a.TransitionOf(i)
+	facts := []m{}
+	for _, f := range CardFields(tr) {
+		facts = append(facts, m{"title": f.Title, "value": f.Value})
+	}
+
+	return m{
+		"type": "message",
+		"attachments": []m{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": m{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []m{
+					{"type": "TextBlock", "text": CardTitle(a.SeverityOf(i), tr), "wrap": true,
+						"weight": "Bolder", "size": "Medium", "color": TeamsColor(a.SeverityOf(i))},
+					{"type": "TextBlock", "text": a.Messages[i].Error(), "wrap": true},
+					{"type": "FactSet", "facts": facts},
+				},
+			},
+		}},
+	}
+}
+
+// Alert posts a card for every alert message to teams
+func (t Teams) Alert(a *Alert) error {
+	err := sendCards(a, t.WebhookURL, func(i int) interface{} { return t.Card(a, i) })
+	if err != nil {
+		return errors.Wrap(err, "error sending teams cards")
+	}
+
+	log.Println("sent alert to teams")
+	return nil
+}
+
+// GoogleChat posts a card for every alert message to a Google Chat space through an incoming
+// webhook
+type GoogleChat struct {
+	WebhookURL string
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (g GoogleChat) Name() string {
+	return "googlechat"
+}
+
+// Valid returns an error if google chat settings are invalid
+func (g GoogleChat) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(GoogleChat{}, g) {
+		return nil // assume that google chat was omitted
+	}
+
+	if g.WebhookURL == "" {
+		errString = append(errString, ErrGoogleChatWebhookURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "google chat settings validation fail")
+}
+
+// Card returns the google chat message with the card of the alert message at index i
+func (g GoogleChat) Card(a *Alert, i int) interface{} {
+	type m map[string]interface{}
+
+	tr := a.TransitionOf(i)
+	widgets := []m{{"textParagraph": m{"text": a.Messages[i].Error()}}}
+	for _, f := range CardFields(tr) {
+		widgets = append(widgets, m{"decoratedText": m{"topLabel": f.Title, "text": f.Value}})
+	}
+
+	return m{
+		"cardsV2": []m{{
+			"cardId": fmt.Sprintf("alert-%d", i),
+			"card": m{
+				"header":   m{"title": CardTitle(a.SeverityOf(i), tr)},
+				"sections": []m{{"widgets": widgets}},
+			},
+		}},
+	}
+}
+
+// Alert posts a card for every alert message to google chat
+func (g GoogleChat) Alert(a *Alert) error {
+	err := sendCards(a, g.WebhookURL, func(i int) interface{} { return g.Card(a, i) })
+	if err != nil {
+		return errors.Wrap(err, "error sending google chat cards")
+	}
+
+	log.Println("sent alert to google chat")
+	return nil
+}
+
+// ChatUsername is the name the chat alerters post their messages as
+const ChatUsername = "docker-alertd"
+
+// SeverityColor returns the RGB color of the severity for the chat messages, red for
+// critical, amber for warning and green for recovered
+func SeverityColor(sev Severity) int {
+	switch sev {
+	case SeverityCritical:
+		return 0xc62828
+	case SeverityWarning:
+		return 0xb07800
+	default:
+		return 0x2a7d2a
+	}
+}
+
+// ChatAttachment is a message attachment of the slack compatible formats of Mattermost and
+// Rocket.Chat
+type ChatAttachment struct {
+	Fallback string      `json:"fallback"`
+	Color    string      `json:"color"`
+	Title    string      `json:"title"`
+	Text     string      `json:"text"`
+	Fields   []ChatField `json:"fields"`
+}
+
+// ChatField is a field of a ChatAttachment
+type ChatField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// ChatAttachments returns an attachment for every alert message
+func ChatAttachments(a *Alert) []ChatAttachment {
+	attachments := []ChatAttachment{}
+	for i, msg := range a.Messages {
+		fields := []ChatField{}
+		for _, f := range CardFields(a.TransitionOf(i)) {
+			fields = append(fields, ChatField{Title: f.Title, Value: f.Value, Short: true})
+		}
+
+		attachments = append(attachments, ChatAttachment{
+			Fallback: fmt.Sprintf("[%s] %s", a.SeverityOf(i), msg),
+			Color:    fmt.Sprintf("#%06x", SeverityColor(a.SeverityOf(i))),
+			Title:    CardTitle(a.SeverityOf(i), a.TransitionOf(i)),
+			Text:     msg.Error(),
+			Fields:   fields,
+		})
+	}
+	return attachments
+}
+
+// Discord posts the alert messages as embeds to a Discord channel webhook
+type Discord struct {
+	WebhookURL string
+}
+
+// DiscordEmbed is a rich embed of a Discord message
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Color       int                 `json:"color"`
+	Fields      []DiscordEmbedField `json:"fields"`
+}
+
+// DiscordEmbedField is a field of a DiscordEmbed
+type DiscordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// DiscordMaxEmbeds is the number of embeds Discord accepts in a single message
+const DiscordMaxEmbeds = 10
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (d Discord) Name() string {
+	return "discord"
+}
+
+// Valid returns an error if discord settings are invalid
+func (d Discord) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Discord{}, d) {
+		return nil // assume that discord was omitted
+	}
+
+	if d.WebhookURL == "" {
+		errString = append(errString, ErrDiscordWebhookURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "discord settings validation fail")
+}
+
+// Embeds returns an embed for every alert message
+func (d Discord) Embeds(a *Alert) []DiscordEmbed {
+	embeds := []DiscordEmbed{}
+	for i, msg := range a.Messages {
+		fields := []DiscordEmbedField{}
+		for _, f := range CardFields(a.TransitionOf(i)) {
+			fields = append(fields, DiscordEmbedField{Name: f.Title, Value: f.Value, Inline: true})
+		}
+
+		embeds = append(embeds, DiscordEmbed{
+			Title:       CardTitle(a.SeverityOf(i), a.TransitionOf(i)),
+			Description: msg.Error(),
+			Color:       SeverityColor(a.SeverityOf(i)),
+			Fields:      fields,
+		})
+	}
+	return embeds
+}
+
+// Alert posts the alert to discord, DiscordMaxEmbeds messages at a time
+func (d Discord) Alert(a *Alert) error {
+	embeds := d.Embeds(a)
+	errString := []string{}
+	for len(embeds) > 0 {
+		n := len(embeds)
+		if n > DiscordMaxEmbeds {
+			n = DiscordMaxEmbeds
+		}
+
+		body := map[string]interface{}{"username": ChatUsername, "embeds": embeds[:n]}
+		if err := postJSON(d.WebhookURL, body); err != nil {
+			errString = append(errString, err.Error())
+		}
+		embeds = embeds[n:]
+	}
+
+	if len(errString) > 0 {
+		return errors.Wrap(errors.New(strings.Join(errString, ", ")),
+			"error sending discord message")
+	}
+
+	log.Println("sent alert to discord")
+	return nil
+}
+
+// Mattermost posts the alert messages as attachments to a Mattermost incoming webhook, the
+// Channel overrides the channel of the webhook when it is set
+type Mattermost struct {
+	WebhookURL string
+	Channel    string
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (m Mattermost) Name() string {
+	return "mattermost"
+}
+
+// Valid returns an error if mattermost settings are invalid
+func (m Mattermost) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(Mattermost{}, m) {
+		return nil // assume that mattermost was omitted
+	}
+
+	if m.WebhookURL == "" {
+		errString = append(errString, ErrMattermostWebhookURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "mattermost settings validation fail")
+}
+
+// Alert posts the alert to mattermost
+func (m Mattermost) Alert(a *Alert) error {
+	body := map[string]interface{}{
+		"username":    ChatUsername,
+		"attachments": ChatAttachments(a),
+	}
+	if m.Channel != "" {
+		body["channel"] = m.Channel
+	}
+
+	if err := postJSON(m.WebhookURL, body); err != nil {
+		return errors.Wrap(err, "error sending mattermost message")
+	}
+
+	log.Println("sent alert to mattermost")
+	return nil
+}
+
+// RocketChat posts the alert messages as attachments to a Rocket.Chat incoming webhook, the
+// Channel overrides the channel of the webhook when it is set
+type RocketChat struct {
+	WebhookURL string
+	Channel    string
+}
+
+// Name is the name of the alerter that containers use to route their alerts to it
+func (r RocketChat) Name() string {
+	return "rocketchat"
+}
+
+// Valid returns an error if rocket.chat settings are invalid
+func (r RocketChat) Valid() error {
+	errString := []string{}
+
+	if reflect.DeepEqual(RocketChat{}, r) {
+		return nil // assume that rocket.chat was omitted
+	}
+
+	if r.WebhookURL == "" {
+		errString = append(errString, ErrRocketChatWebhookURL.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "rocket.chat settings validation fail")
+}
+
+// Alert posts the alert to rocket.chat
+func (r RocketChat) Alert(a *Alert) error {
+	body := map[string]interface{}{
+		"alias":       ChatUsername,
+		"text":        fmt.Sprintf("[%s] docker-alertd", a.Severity()),
+		"attachments": ChatAttachments(a),
+	}
+	if r.Channel != "" {
+		body["channel"] = r.Channel
+	}
+
+	if err := postJSON(r.WebhookURL, body); err != nil {
+		return errors.Wrap(err, "error sending rocket.chat message")
+	}
+
+	log.Println("sent alert to rocket.chat")
+	return nil
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookAlert(t *testing.T) {
+	var got struct {
+		Severity string
+		Events   []struct{ Host, Check, Text string }
+	}
+	auth := ""
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		b, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Errorf("expected a JSON body, got: %s", b)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "web")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU \"quoted\"")
+
+	w := Webhook{
+		URL:     ts.URL,
+		Headers: map[string]string{"authorization": "Bearer token"},
+		Template: `{"severity": {{json .Severity}}, "events": [{{range $i, $m := .Messages}}` +
+			`{{if $i}},{{end}}{"host": {{json $m.Container}}, "check": {{json $m.Check}}, ` +
+			`"text": {{json $m.Message}}}{{end}}]}`,
+	}
+	if err := w.Valid(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Alert(c.Alert); err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case auth != "Bearer token":
+		t.Errorf("expected the authorization header, got: %q", auth)
+	case got.Severity != "CRITICAL" || len(got.Events) != 1:
+		t.Errorf("unexpected body: %+v", got)
+	case got.Events[0].Host != "web" || got.Events[0].Check != "maxCpu":
+		t.Errorf("unexpected event: %+v", got.Events[0])
+	}
+
+	if err := (Webhook{URL: ts.URL, Template: "{{.Nope"}).Valid(); err == nil {
+		t.Errorf("expected an invalid template error")
+	}
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer fail.Close()
+
+	if err := (Webhook{URL: fail.URL}).Alert(c.Alert); err == nil {
+		t.Errorf("expected an error for a failed request")
+	}
+
+	// an endpoint that never answers times out instead of holding up the alert
+	done := make(chan struct{})
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hang.Close()
+	defer close(done)
+
+	defer func(d time.Duration) { alertClient.Timeout = d }(alertClient.Timeout)
+	alertClient.Timeout = 50 * time.Millisecond
+	if err := (Webhook{URL: hang.URL}).Alert(c.Alert); err == nil {
+		t.Errorf("expected an error for a request that timed out")
+	}
+}
+
+func TestSlackPushoverAlert(t *testing.T) {
+	var text string
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Text string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+			text = body.Text
+		}
+	}))
+	defer ok.Close()
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer fail.Close()
+
+	done := make(chan struct{})
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer hang.Close()
+	defer close(done)
+
+	defer func(d time.Duration) { alertClient.Timeout = d }(alertClient.Timeout)
+	alertClient.Timeout = 50 * time.Millisecond
+
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "web")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU \"quoted\"")
+
+	if err := (Slack{WebhookURL: ok.URL}).Alert(c.Alert); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, `CPU "quoted"`) {
+		t.Errorf("expected the alert to be sent as JSON, got: %q", text)
+	}
+
+	for _, u := range []string{fail.URL, hang.URL} {
+		if err := (Slack{WebhookURL: u}).Alert(c.Alert); err == nil {
+			t.Errorf("%s: expected slack to fail", u)
+		}
+		if err := (Pushover{APIURL: u}).Alert(c.Alert); err == nil {
+			t.Errorf("%s: expected pushover to fail", u)
+		}
+	}
+}
+
+func TestPagerDutyEvents(t *testing.T) {
+	events := []PagerDutyEvent{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := PagerDutyEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	p := PagerDuty{RoutingKey: "key", URL: ts.URL}
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "web")
+
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	if err := p.Alert(c.Alert); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Alert.Clear()
+	c.CheckUsage(c.CPUCheck, 10, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	if err := p.Alert(c.Alert); err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case len(events) != 2:
+		t.Fatalf("expected 2 events, got: %+v", events)
+	case events[0].EventAction != "trigger" || events[0].Payload == nil:
+		t.Errorf("expected a trigger event, got: %+v", events[0])
+	case events[0].Payload.Severity != "critical" || events[0].Payload.Component != "web":
+		t.Errorf("unexpected trigger payload: %+v", events[0].Payload)
+	case events[1].EventAction != "resolve" || events[1].Payload != nil:
+		t.Errorf("expected a resolve event, got: %+v", events[1])
+	case events[0].DedupKey != events[1].DedupKey || events[0].RoutingKey != "key":
+		t.Errorf("expected the events to have the same dedup key: %+v", events)
+	}
+}
+
+func TestOpsgenieAlert(t *testing.T) {
+	paths, bodies := []string{}, []map[string]interface{}{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey key" {
+			t.Errorf("unexpected authorization: %q", r.Header.Get("Authorization"))
+		}
+		b := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		paths, bodies = append(paths, r.URL.RequestURI()), append(bodies, b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	o := Opsgenie{APIKey: "key", URL: ts.URL}
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "web")
+	c.ID, c.Image = "abc123", "nginx:latest"
+
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	if err := o.Alert(c.Alert); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Alert.Clear()
+	c.CheckUsage(c.CPUCheck, 10, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	if err := o.Alert(c.Alert); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 requests, got: %s", paths)
+	}
+
+	details, _ := bodies[0]["details"].(map[string]interface{})
+	switch {
+	case paths[0] != "/v2/alerts" || bodies[0]["priority"] != "P1":
+		t.Errorf("expected a P1 alert to be created, got: %s %v", paths[0], bodies[0])
+	case bodies[0]["alias"] != "docker-alertd/web/maxCpu":
+		t.Errorf("unexpected alias: %v", bodies[0]["alias"])
+	case details["image"] != "nginx:latest" || details["id"] != "abc123" ||
+		details["value"] != "95" || details["limit"] != "90":
+		t.Errorf("unexpected details: %v", details)
+	case paths[1] != "/v2/alerts/docker-alertd%2Fweb%2FmaxCpu/close?identifierType=alias":
+		t.Errorf("expected the alert to be closed by its alias, got: %s", paths[1])
+	}
+}
+
+func TestChatCards(t *testing.T) {
+	bodies := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+	}))
+	defer ts.Close()
+
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90), MaxMem: uint64P(100)}, "web")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	c.CheckUsage(c.MemCheck, 120, ErrMemCheckFail, ErrMemCheckRecovered, "Memory")
+
+	for _, a := range []Alerter{Teams{WebhookURL: ts.URL}, GoogleChat{WebhookURL: ts.URL}} {
+		bodies = []string{}
+		if err := a.Alert(c.Alert); err != nil {
+			t.Fatal(err)
+		}
+
+		switch {
+		case len(bodies) != 2:
+			t.Errorf("%s: expected a card per message, got: %d", a.Name(), len(bodies))
+		case !strings.Contains(bodies[0], `[CRITICAL] web maxCpu`):
+			t.Errorf("%s: expected the title in the card: %s", a.Name(), bodies[0])
+		case !strings.Contains(bodies[1], `"Limit"`) || !strings.Contains(bodies[1], `"120"`):
+			t.Errorf("%s: expected the value and limit in the card: %s", a.Name(), bodies[1])
+		}
+	}
+}
+
+func TestChatAttachments(t *testing.T) {
+	bodies := []map[string]interface{}{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Error(err)
+		}
+		bodies = append(bodies, b)
+	}))
+	defer ts.Close()
+
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "web")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	c.CheckUsage(c.CPUCheck, 10, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+
+	for _, a := range []Alerter{Mattermost{WebhookURL: ts.URL, Channel: "alerts"},
+		RocketChat{WebhookURL: ts.URL}} {
+		bodies = []map[string]interface{}{}
+		if err := a.Alert(c.Alert); err != nil {
+			t.Fatal(err)
+		}
+
+		attachments, _ := bodies[0]["attachments"].([]interface{})
+		if len(attachments) != 2 {
+			t.Fatalf("%s: expected an attachment per message, got: %v", a.Name(), bodies[0])
+		}
+
+		fail, _ := attachments[0].(map[string]interface{})
+		recovered, _ := attachments[1].(map[string]interface{})
+		if fail["color"] != "#c62828" || recovered["color"] != "#2a7d2a" {
+			t.Errorf("%s: expected red and green attachments, got: %v", a.Name(), attachments)
+		}
+	}
+
+	if bodies[0]["channel"] != nil {
+		t.Errorf("expected no channel for rocket.chat, got: %v", bodies[0]["channel"])
+	}
+
+	// discord takes 10 embeds per message
+	a := &Alert{Messages: []error{}}
+	for i := 0; i < 11; i++ {
+		a.Add(ErrUnknown, nil, "web", "")
+	}
+
+	bodies = []map[string]interface{}{}
+	if err := (Discord{WebhookURL: ts.URL}).Alert(a); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := bodies[0]["embeds"].([]interface{})
+	switch {
+	case len(bodies) != 2 || len(first) != 10:
+		t.Errorf("expected the embeds to be split in 2 messages, got: %v", bodies)
+	case first[0].(map[string]interface{})["color"] != float64(0xc62828):
+		t.Errorf("expected a red embed, got: %v", first[0])
+	}
+}
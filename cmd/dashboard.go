@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the number of values kept for the sparklines and the number of recent alerts shown
+const (
+	DashboardSeriesLen  = 60
+	DashboardHistoryLen = 50
+)
+
+// dashboard feeds the web dashboard, it is nil when the http server is not running
+var dashboard *Dashboard
+
+// DashboardContainer is the state of a container on the dashboard, Series has the recent
+// values of each metric check
+type DashboardContainer struct {
+	ContainerStatus
+	Series map[string][]uint64 `json:"series"`
+}
+
+// DashboardSnapshot is what the dashboard shows, it is sent to the browsers on every update
+type DashboardSnapshot struct {
+	Time       time.Time            `json:"time"`
+	Containers []DashboardContainer `json:"containers"`
+	History    []HistoryRecord      `json:"history"`
+}
+
+// Dashboard keeps the recent values and alerts for the web dashboard and sends the updates
+// to the browsers that are connected to the events stream
+type Dashboard struct {
+	mu       sync.Mutex
+	series   map[string]map[string][]uint64
+	history  []HistoryRecord
+	snapshot []byte
+	clients  map[chan []byte]bool
+}
+
+// NewDashboard returns an empty dashboard
+func NewDashboard() *Dashboard {
+	return &Dashboard{
+		series:  map[string]map[string][]uint64{},
+		history: []HistoryRecord{},
+		clients: map[chan []byte]bool{},
+	}
+}
+
+// Update records the latest values of the containers and sends the new snapshot to the
+// browsers, it is called after every monitor iteration and event
+func (d *Dashboard) Update(cnt []AlertdContainer) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	series := map[string]map[string][]uint64{}
+	containers := []DashboardContainer{}
+	for i := range cnt {
+		c := &cnt[i]
+		series[c.Name] = map[string][]uint64{}
+
+		for k, m := range c.MetricChecks() {
+			if m == nil || m.Limit == nil || m.Value == nil {
+				continue
+			}
+
+			s := append(d.series[c.Name][k], *m.Value)
+			if len(s) > DashboardSeriesLen {
+				s = s[len(s)-DashboardSeriesLen:]
+			}
+			series[c.Name][k] = s
+		}
+
+		containers = append(containers, DashboardContainer{
+			ContainerStatus: c.Status(),
+			Series:          series[c.Name],
+		})
+	}
+	d.series = series // the series of containers that are gone are dropped
+
+	d.publish(DashboardSnapshot{
+		Time:       time.Now(),
+		Containers: containers,
+		History:    d.history,
+	})
+}
+
+// AddHistory adds the alerts to the recent alerts on the dashboard, newest first
+func (d *Dashboard) AddHistory(records []HistoryRecord) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	h := []HistoryRecord{}
+	for i := len(records) - 1; i >= 0; i-- {
+		h = append(h, records[i])
+	}
+	h = append(h, d.history...)
+	if len(h) > DashboardHistoryLen {
+		h = h[:DashboardHistoryLen]
+	}
+	d.history = h
+}
+
+// publish sends the snapshot to all of the browsers, a browser that has not read the
+// previous snapshot yet skips it and gets this one instead
+func (d *Dashboard) publish(s DashboardSnapshot) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		log.Println(errors.Wrap(err, "dashboard snapshot"))
+		return
+	}
+	d.snapshot = b
+
+	for ch := range d.clients {
+		// the channels only have room for one snapshot, drop the unread one
+		select {
+		case <-ch:
+		default:
+		}
+
+		select {
+		case ch <- b:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel that gets every snapshot, starting with the current one
+func (d *Dashboard) Subscribe() chan []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ch := make(chan []byte, 1)
+	if d.snapshot != nil {
+		ch <- d.snapshot
+	}
+	d.clients[ch] = true
+	return ch
+}
+
+// Unsubscribe stops sending the snapshots to the channel
+func (d *Dashboard) Unsubscribe(ch chan []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.clients, ch)
+}
+
+// ServeEvents streams the snapshots to the browser as server-sent events
+func (d *Dashboard) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := d.Subscribe()
+	defer d.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+
+	for {
+		select {
+		case b := <-ch:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+				return
+			}
+			f.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// dashboardHTML is the dashboard page, it has no external assets so it works on hosts
+// without internet access
+const dashboardHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>docker-alertd</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; background: #fafafa; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; width: 100%; background: #fff; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #ddd; }
+.OK { color: #2a7d2a; }
+.WARNING { color: #b07800; font-weight: bold; }
+.CRITICAL { color: #c62828; font-weight: bold; }
+.muted { color: #888; }
+svg { vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>docker-alertd <span id="updated" class="muted"></span></h1>
+
+<h2>Active alerts</h2>
+<table><tbody id="alerts"></tbody></table>
+
+<h2>Containers</h2>
+<table>
+<thead><tr><th>Container</th><th>Check</th><th>Severity</th><th>Value</th><th>Limit</th>
+<th>Recent</th><th>Changed</th></tr></thead>
+<tbody id="containers"></tbody>
+</table>
+
+<h2>Recent alerts</h2>
+<table><tbody id="history"></tbody></table>
+
+<script>
+function esc(s) {
+  return String(s === undefined || s === null ? "" : s).replace(/[&<>"]/g, function(c) {
+    return {"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c];
+  });
+}
+
+function time(t) {
+  return t ? new Date(t).toLocaleString() : "";
+}
+
+function sparkline(values, limit) {
+  if (!values || values.length < 2) return "";
+  var w = 120, h = 24, max = Math.max.apply(null, values.concat([limit || 0])) || 1;
+  var points = values.map(function(v, i) {
+    return (i * w / (values.length - 1)).toFixed(1) + "," + (h - v * h / max).toFixed(1);
+  }).join(" ");
+  var l = limit ? '<line x1="0" x2="' + w + '" y1="' + (h - limit * h / max) + '" y2="' +
+    (h - limit * h / max) + '" stroke="#c62828" stroke-dasharray="2,2"/>' : "";
+  return '<svg width="' + w + '" height="' + h + '">' + l +
+    '<polyline fill="none" stroke="#1565c0" points="' + points + '"/></svg>';
+}
+
+function render(s) {
+  document.getElementById("updated").textContent = "updated " + time(s.time);
+
+  var alerts = "", rows = "";
+  s.containers.forEach(function(c) {
+    c.checks.forEach(function(k) {
+      var cls = esc(k.severity);
+      if (k.alertActive) {
+        alerts += "<tr><td>" + esc(c.name) + "</td><td>" + esc(k.name) + '</td><td class="' +
+          cls + '">' + cls + (k.flapping ? " (flapping)" : "") + "</td><td>" +
+          time(k.changed) + "</td></tr>";
+      }
+      rows += "<tr><td>" + esc(c.name) + "</td><td>" + esc(k.name) + '</td><td class="' + cls +
+        '">' + cls + "</td><td>" + esc(k.value) + "</td><td>" + esc(k.limit) + "</td><td>" +
+        sparkline(c.series[k.name], k.limit) + '</td><td class="muted">' + time(k.changed) +
+        "</td></tr>";
+    });
+  });
+  document.getElementById("alerts").innerHTML = alerts ||
+    '<tr><td class="muted">no active alerts</td></tr>';
+  document.getElementById("containers").innerHTML = rows;
+
+  document.getElementById("history").innerHTML = s.history.map(function(r) {
+    return '<tr><td class="muted">' + time(r.time) + '</td><td class="' + esc(r.severity) +
+      '">' + esc(r.severity) + "</td><td>" + esc(r.message) +
+      (r.silenced ? ' <span class="muted">(silenced)</span>' : "") + "</td></tr>";
+  }).join("") || '<tr><td class="muted">no alerts yet</td></tr>';
+}
+
+new EventSource("events").onmessage = function(e) {
+  render(JSON.parse(e.data));
+};
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDashboardUpdate(t *testing.T) {
+	d := NewDashboard()
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "test")
+	cnt := []AlertdContainer{c}
+
+	for i := uint64(0); i < DashboardSeriesLen+5; i++ {
+		cnt[0].CheckUsage(cnt[0].CPUCheck, i, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+		d.Update(cnt)
+	}
+	d.AddHistory([]HistoryRecord{{Message: "first"}, {Message: "second"}})
+	d.Update(cnt)
+
+	// a new browser gets the current snapshot straight away
+	ch := d.Subscribe()
+	defer d.Unsubscribe(ch)
+
+	var s DashboardSnapshot
+	if err := json.Unmarshal(<-ch, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case len(s.Containers) != 1:
+		t.Fatalf("expected 1 container, got: %d", len(s.Containers))
+	case len(s.Containers[0].Series["maxCpu"]) != DashboardSeriesLen:
+		t.Errorf("expected the series to be capped at %d values, got: %d", DashboardSeriesLen,
+			len(s.Containers[0].Series["maxCpu"]))
+	case s.Containers[0].Series["maxCpu"][DashboardSeriesLen-1] != DashboardSeriesLen+4:
+		t.Errorf("expected the series to end with the last value")
+	case len(s.History) != 2 || s.History[0].Message != "second":
+		t.Errorf("expected the newest alert first, got: %+v", s.History)
+	}
+
+	// the series of containers that are gone are dropped
+	d.Update([]AlertdContainer{})
+	if len(d.series) != 0 {
+		t.Errorf("expected the series to be dropped, got: %v", d.series)
+	}
+
+	// a browser that did not read the last snapshot gets the newest one
+	d.Update(cnt)
+	if err := json.Unmarshal(<-ch, &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Containers) != 1 {
+		t.Errorf("expected the newest snapshot, got: %d containers", len(s.Containers))
+	}
+}
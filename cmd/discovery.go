@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+// DefaultDiscoveryPrefix is the prefix of the container labels read in discovery mode
+const DefaultDiscoveryPrefix = "alertd"
+
+// LabelPrefix returns the prefix of the labels (with the trailing dot) that discovery reads
+func (c *Conf) LabelPrefix() string {
+	if c.DiscoveryPrefix == "" {
+		return DefaultDiscoveryPrefix + "."
+	}
+	return c.DiscoveryPrefix + "."
+}
+
+// LabelSettings returns the settings from the labels that start with the prefix, with the
+// prefix removed from the keys
+func LabelSettings(prefix string, labels map[string]string) map[string]interface{} {
+	settings := map[string]interface{}{}
+	for k, v := range labels {
+		if strings.HasPrefix(k, prefix) {
+			settings[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return settings
+}
+
+// ContainerFromLabels decodes the label settings over the base container settings, the
+// label values are strings so they are converted to the type of each setting and lists
+// are comma separated (alertd.acceptedExitCodes=0,143). Labels which are not a setting are
+// an error, so that a misspelled label does not leave the container without its checks.
+func ContainerFromLabels(base Container, settings map[string]interface{}) (Container, error) {
+	c := base
+	md := &mapstructure.Metadata{}
+	d, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToSliceHookFunc(","),
+		WeaklyTypedInput: true,
+		Metadata:         md,
+		Result:           &c,
+	})
+	if err != nil {
+		return base, err
+	}
+
+	if err := d.Decode(settings); err != nil {
+		return base, err
+	}
+
+	if len(md.Unused) > 0 {
+		sort.Strings(md.Unused)
+		return base, errors.Wrap(ErrUnknownLabel, strings.Join(md.Unused, ", "))
+	}
+
+	return c, c.Valid()
+}
+
+// fileContainer returns the index of the container in the config file which has the name
+// or ID of the listed container, names which are not a container ID only match the name
+func (c *Conf) fileContainer(l types.Container) (int, bool) {
+	for i, v := range c.Containers {
+		switch {
+		case v.Name == "":
+			continue
+		case v.Name == ContainerName(l):
+			return i, true
+		case IsContainerID(v.Name) && strings.HasPrefix(l.ID, v.Name):
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// MergeLabels applies the label settings of the listed containers to the containers that
+// are in the config file, the labels take precedence over the config file. It is done once
+// at startup, label changes on these containers are picked up when docker-alertd restarts.
+func (c *Conf) MergeLabels(list []types.Container) {
+	for _, l := range list {
+		settings := LabelSettings(c.LabelPrefix(), l.Labels)
+		i, ok := c.fileContainer(l)
+		if len(settings) == 0 || !ok {
+			continue
+		}
+
+		merged, err := ContainerFromLabels(c.Containers[i], settings)
+		if err == nil {
+			err = c.ValidRoute(merged.Alerters)
+		}
+		if err != nil {
+			log.Println(errors.Wrap(err, ContainerName(l)+" labels"))
+			continue
+		}
+
+		merged.Name = c.Containers[i].Name
+		c.Containers[i] = merged
+		log.Printf("merged labels of %s into the config", merged.Name)
+	}
+}
+
+// findDiscovered returns the discovered checker of the container ID
+func findDiscovered(cnt []AlertdContainer, id string) (AlertdContainer, bool) {
+	for _, a := range cnt {
+		if a.Discovered && a.ID == id {
+			return a, true
+		}
+	}
+	return AlertdContainer{}, false
+}
+
+// hasChecker returns true if one of the checkers is for the container ID
+func hasChecker(cnt []AlertdContainer, id string) bool {
+	for _, a := range cnt {
+		if a.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverContainers creates checkers for the listed containers that have settings in their
+// labels and are not in the config file or matched by a selector, and retires the checkers
+// of the discovered containers that are gone, their active alerts are recovered in a. All of
+// the other checkers are kept as they are.
+func DiscoverContainers(c *Conf, cnt []AlertdContainer, list []types.Container,
+	a *Alert) []AlertdContainer {
+
+	if c.badLabels == nil {
+		c.badLabels = map[string]bool{}
+	}
+
+	resolved := []AlertdContainer{}
+	for _, r := range cnt {
+		if !r.Discovered {
+			resolved = append(resolved, r)
+		}
+	}
+
+	for _, l := range list {
+		settings := LabelSettings(c.LabelPrefix(), l.Labels)
+		if _, ok := c.fileContainer(l); len(settings) == 0 || ok {
+			continue
+		}
+
+		if hasChecker(resolved, l.ID) {
+			continue // a selector already monitors the container
+		}
+
+		if r, ok := findDiscovered(cnt, l.ID); ok {
+			resolved = append(resolved, r)
+			continue
+		}
+
+		v, err := ContainerFromLabels(Container{}, settings)
+		if err == nil {
+			err = c.ValidRoute(v.Alerters)
+		}
+		if err != nil {
+			// only log the bad labels once, the container is listed again every loop
+			if !c.badLabels[l.ID] {
+				log.Println(errors.Wrap(err, ContainerName(l)+" labels"))
+			}
+			c.badLabels[l.ID] = true
+			continue
+		}
+
+		n := NewAlertdContainer(v, ContainerName(l))
+		n.ID = l.ID
+		n.Discovered = true
+		n.Unchecked = true
+		resolved = append(resolved, n)
+		log.Printf("monitoring %s (discovered from labels)", n.Name)
+	}
+
+	for _, r := range cnt {
+		if _, ok := findDiscovered(resolved, r.ID); r.Discovered && !ok {
+			log.Printf("stopped monitoring %s, it is gone or monitored by a selector", r.Name)
+			r.Alert.Clear()
+			r.Retire()
+			a.Concat(r.Alert)
+		}
+	}
+
+	return resolved
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestContainerFromLabels(t *testing.T) {
+	labels := map[string]string{
+		"alertd.maxCpu":            "80",
+		"alertd.expectedRunning":   "true",
+		"alertd.alerters":          "slack,email",
+		"alertd.acceptedExitCodes": "0,143",
+		"com.example.other":        "ignored",
+	}
+
+	base := Container{Name: "web", MaxCPU: uint64P(20), MaxMem: uint64P(100)}
+	c, err := ContainerFromLabels(base, LabelSettings("alertd.", labels))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Container{
+		Name:              "web",
+		MaxCPU:            uint64P(80),
+		MaxMem:            uint64P(100),
+		ExpectedRunning:   boolP(true),
+		Alerters:          []string{"slack", "email"},
+		AcceptedExitCodes: []int{0, 143},
+	}
+
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected container: %+v\ngot: %+v", expected, c)
+	}
+
+	_, err = ContainerFromLabels(Container{}, map[string]interface{}{"memUnit": "KB"})
+	if !ErrContainsErr(err, ErrMemUnit) {
+		t.Errorf("expected err: %s, got: %v", ErrMemUnit, err)
+	}
+
+	// a misspelled label is an error instead of a container without the check
+	labels["alertd.maxCpus"] = "80"
+	_, err = ContainerFromLabels(base, LabelSettings("alertd.", labels))
+	switch {
+	case !ErrContainsErr(err, ErrUnknownLabel):
+		t.Errorf("expected err: %s, got: %v", ErrUnknownLabel, err)
+	case !strings.Contains(err.Error(), "maxCpus"):
+		t.Errorf("expected the error to name the label, got: %s", err)
+	}
+}
+
+func TestDiscoverContainers(t *testing.T) {
+	c := &Conf{
+		Discovery: true,
+		Containers: []Container{
+			{Name: "abc"}, // looks like the start of an ID
+			{NameGlob: "worker_*"},
+		},
+	}
+	labels := map[string]string{"alertd.maxCpu": "80"}
+	list := []types.Container{
+		{ID: "abc123", Names: []string{"/web"}, Labels: labels},
+		{ID: "def456", Names: []string{"/worker_1"}, Labels: labels},
+		{ID: "abc789", Names: []string{"/abc"}, Labels: labels},
+	}
+
+	a := &Alert{Messages: []error{}}
+	cnt := ResolveSelectors(c, InitCheckers(c), list, a)
+	cnt = DiscoverContainers(c, cnt, list, a)
+
+	checkers := map[string]int{}
+	for _, r := range cnt {
+		checkers[r.Name]++
+	}
+
+	expected := map[string]int{"abc": 1, "web": 1, "worker_1": 1}
+	if !reflect.DeepEqual(checkers, expected) {
+		t.Errorf("expected checkers: %v, got: %v", expected, checkers)
+	}
+}
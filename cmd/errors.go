@@ -8,31 +8,87 @@ import (
 
 // these errors are for the purpose of being able to compare them later
 var (
-	ErrEmptyConfig           = errors.New("the configuration is completely empty (check config file)")
-	ErrEmailNoSMTP           = errors.New("no email SMTP server")
-	ErrEmailNoTo             = errors.New("no email to addresses")
-	ErrEmailNoFrom           = errors.New("no email from addresses")
-	ErrEmailNoPass           = errors.New("no email password")
-	ErrEmailNoPort           = errors.New("no email port")
-	ErrEmailNoSubject        = errors.New("no email subject")
-	ErrSlackNoWebHookURL     = errors.New("no slack webhook url")
-	ErrNoContainers          = errors.New("there were no containers found in the configuration file")
-	ErrExistCheckFail        = errors.New("Existence check failure")
-	ErrExistCheckRecovered   = errors.New("Existence check recovered")
-	ErrRunningCheckFail      = errors.New("Running check failure")
-	ErrRunningCheckRecovered = errors.New("Running check recovered")
-	ErrCPUCheckFail          = errors.New("CPU check failure")
-	ErrCPUCheckRecovered     = errors.New("CPU check recovered")
-	ErrMemCheckFail          = errors.New("Memory check failure")
-	ErrMemCheckRecovered     = errors.New("Memory check recovered")
-	ErrMinPIDCheckFail       = errors.New("Min PID check Failure")
-	ErrMinPIDCheckRecovered  = errors.New("Min PID check recovered")
-	ErrMaxPIDCheckFail       = errors.New("Max PID check Failure")
-	ErrMaxPIDCheckRecovered  = errors.New("Max PID check recovered")
-	ErrUnknown               = errors.New("Received an unknown error")
-	ErrPushoverAPIToken      = errors.New("no pushover api token")
-	ErrPushoverUserKey       = errors.New("no pushover user key")
-	ErrPushoverAPIURL        = errors.New("no pushover api url")
+	ErrEmptyConfig                 = errors.New("the configuration is completely empty (check config file)")
+	ErrEmailNoSMTP                 = errors.New("no email SMTP server")
+	ErrEmailNoTo                   = errors.New("no email to addresses")
+	ErrEmailNoFrom                 = errors.New("no email from addresses")
+	ErrEmailNoPass                 = errors.New("no email password")
+	ErrEmailNoPort                 = errors.New("no email port")
+	ErrEmailNoSubject              = errors.New("no email subject")
+	ErrSlackNoWebHookURL           = errors.New("no slack webhook url")
+	ErrNameAndSelector             = errors.New("a container can have a name or selectors, not both")
+	ErrNameRegex                   = errors.New("invalid nameRegex")
+	ErrNameGlob                    = errors.New("invalid nameGlob")
+	ErrUnknownCheck                = errors.New("unknown metric check")
+	ErrWarningLimit                = errors.New("warning must be under the limit of the check (over it for minProcs)")
+	ErrSeverityEscalated           = errors.New("escalated from warning to critical")
+	ErrSeverityDeescalated         = errors.New("de-escalated from critical to warning")
+	ErrRecoverLimit                = errors.New("recover must be under the warning and limit of the check (over them for minProcs)")
+	ErrFlapTransitions             = errors.New("flapTransitions must be at least 2")
+	ErrCheckFlapping               = errors.New("check is flapping, alerts are suppressed until it settles")
+	ErrCheckFlapStopped            = errors.New("check stopped flapping")
+	ErrSilenceTime                 = errors.New("silence start and end must be RFC3339 times")
+	ErrSilenceEnd                  = errors.New("silence end must be after its start")
+	ErrSilenceReadOnly             = errors.New("silences can only be changed over http when silenceToken is set")
+	ErrSilenceToken                = errors.New("invalid or missing silence token")
+	ErrUnknownSilence              = errors.New("unknown silence")
+	ErrSchedule                    = errors.New("maintenance schedule must be a cron schedule with 5 fields")
+	ErrMaintenanceDuration         = errors.New("maintenance duration must be at least 1 second")
+	ErrUnknownAlerter              = errors.New("unknown or inactive alerter")
+	ErrNoContainers                = errors.New("there were no containers found in the configuration file")
+	ErrNoLongerMonitored           = errors.New("Container is no longer monitored")
+	ErrExistCheckFail              = errors.New("Existence check failure")
+	ErrExistCheckRecovered         = errors.New("Existence check recovered")
+	ErrRunningCheckFail            = errors.New("Running check failure")
+	ErrRunningCheckRecovered       = errors.New("Running check recovered")
+	ErrHealthCheckFail             = errors.New("Health check failure")
+	ErrHealthCheckRecovered        = errors.New("Health check recovered")
+	ErrRestartCheckFail            = errors.New("Restart check failure")
+	ErrRestartCheckRecovered       = errors.New("Restart check recovered")
+	ErrCPUCheckFail                = errors.New("CPU check failure")
+	ErrCPUCheckRecovered           = errors.New("CPU check recovered")
+	ErrMemCheckFail                = errors.New("Memory check failure")
+	ErrMemCheckRecovered           = errors.New("Memory check recovered")
+	ErrMemPercentCheckFail         = errors.New("Memory percent check failure")
+	ErrMemPercentCheckRecovered    = errors.New("Memory percent check recovered")
+	ErrMemUnit                     = errors.New("memUnit must be one of MB, MiB or GiB")
+	ErrUnknownLabel                = errors.New("unknown label setting")
+	ErrMemPercent                  = errors.New("maxMemPercent must be between 1 and 100")
+	ErrMinPIDCheckFail             = errors.New("Min PID check Failure")
+	ErrMinPIDCheckRecovered        = errors.New("Min PID check recovered")
+	ErrMaxPIDCheckFail             = errors.New("Max PID check Failure")
+	ErrMaxPIDCheckRecovered        = errors.New("Max PID check recovered")
+	ErrNetRxCheckFail              = errors.New("Network rx check failure")
+	ErrNetRxCheckRecovered         = errors.New("Network rx check recovered")
+	ErrNetTxCheckFail              = errors.New("Network tx check failure")
+	ErrNetTxCheckRecovered         = errors.New("Network tx check recovered")
+	ErrNetErrCheckFail             = errors.New("Network packet error check failure")
+	ErrNetErrCheckRecovered        = errors.New("Network packet error check recovered")
+	ErrBlkioReadCheckFail          = errors.New("Block I/O read check failure")
+	ErrBlkioReadCheckRecovered     = errors.New("Block I/O read check recovered")
+	ErrBlkioWriteCheckFail         = errors.New("Block I/O write check failure")
+	ErrBlkioWriteCheckRecovered    = errors.New("Block I/O write check recovered")
+	ErrBlkioReadOpsCheckFail       = errors.New("Block I/O read ops check failure")
+	ErrBlkioReadOpsCheckRecovered  = errors.New("Block I/O read ops check recovered")
+	ErrBlkioWriteOpsCheckFail      = errors.New("Block I/O write ops check failure")
+	ErrBlkioWriteOpsCheckRecovered = errors.New("Block I/O write ops check recovered")
+	ErrUnknown                     = errors.New("Received an unknown error")
+	ErrUnknownRecovered            = errors.New("Unknown error recovered")
+	ErrPushoverAPIToken            = errors.New("no pushover api token")
+	ErrPushoverUserKey             = errors.New("no pushover user key")
+	ErrPushoverAPIURL              = errors.New("no pushover api url")
+	ErrWebhookURL                  = errors.New("no webhook url")
+	ErrWebhookMethod               = errors.New("webhook method must be one of POST, PUT or PATCH")
+	ErrWebhookTemplate             = errors.New("invalid webhook template")
+	ErrWebhookID                   = errors.New("the webhooks in the webhooks list need an id")
+	ErrDuplicateWebhook            = errors.New("duplicate webhook id")
+	ErrPagerDutyRoutingKey         = errors.New("no pagerduty routing key")
+	ErrOpsgenieAPIKey              = errors.New("no opsgenie api key")
+	ErrTeamsWebhookURL             = errors.New("no teams webhook url")
+	ErrGoogleChatWebhookURL        = errors.New("no google chat webhook url")
+	ErrDiscordWebhookURL           = errors.New("no discord webhook url")
+	ErrMattermostWebhookURL        = errors.New("no mattermost webhook url")
+	ErrRocketChatWebhookURL        = errors.New("no rocket.chat webhook url")
 )
 
 // ErrContainsErr returns true if the error string contains the message
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+)
+
+// checkMu keeps the monitor loop and the events stream from running checks on the same
+// containers at the same time
+var checkMu sync.Mutex
+
+// the container events which docker-alertd listens for in events mode
+var watchedEvents = []string{"create", "start", "die", "oom", "kill", "health_status",
+	"destroy"}
+
+// containerIDRegex matches a container ID of at least the length of a short ID
+var containerIDRegex = regexp.MustCompile("^[0-9a-f]{12,64}$")
+
+// IsContainerID returns true if the container name in the config is an ID of a container,
+// names which are shorter than a short ID are names even when they look like hex
+func IsContainerID(name string) bool {
+	return containerIDRegex.MatchString(name)
+}
+
+// MatchesEvent returns true if the event came from this container, the container name in
+// the config can either be the name or the (short) ID of the container
+func (c *AlertdContainer) MatchesEvent(m events.Message) bool {
+	switch {
+	case c.Name == m.Actor.Attributes["name"]:
+		return true
+	case c.ID != "":
+		return c.ID == m.Actor.ID
+	default:
+		return IsContainerID(c.Name) && strings.HasPrefix(m.Actor.ID, c.Name)
+	}
+}
+
+// StoppedState builds the container state from a die event, the container is inspected too
+// late to see it stopped when it is restarted by a restart policy.
+func (c *AlertdContainer) StoppedState(m events.Message) *types.ContainerJSON {
+	exitCode, _ := strconv.Atoi(m.Actor.Attributes["exitCode"])
+
+	state := &types.ContainerState{
+		Status:    "exited",
+		Running:   false,
+		OOMKilled: c.EventOOMKilled,
+		ExitCode:  exitCode,
+	}
+	if c.EventSignal != "" {
+		state.Error = fmt.Sprintf("killed with signal %s", c.EventSignal)
+	}
+
+	return &types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			Name:  c.Name,
+			State: state,
+		},
+	}
+}
+
+// HandleEvent feeds a docker event into the static checks of the container
+func (c *AlertdContainer) HandleEvent(m events.Message, cli *client.Client) {
+	switch {
+	case m.Action == "oom":
+		c.EventOOMKilled = true
+
+	case m.Action == "kill":
+		c.EventSignal = m.Actor.Attributes["signal"]
+
+	case m.Action == "die":
+		if c.RunningCheck.Expected != nil {
+			c.CheckRunning(c.StoppedState(m))
+		}
+		c.LastInspect = nil // nothing can time out until the container is started again
+		c.EventOOMKilled = false
+		c.EventSignal = ""
+
+	case m.Action == "destroy":
+		c.LastInspect = nil
+		c.CheckExists(errors.Errorf("Error: No such container: %s", c.Name))
+
+	case strings.HasPrefix(m.Action, "health_status"):
+		j, err := ContainerInspect(c, cli)
+		if err == nil {
+			c.LastInspect = j
+		}
+		if err == nil && c.HealthCheck.Expected != nil && *c.HealthCheck.Expected {
+			c.CheckHealth(j)
+		}
+
+	default: // create and start
+		j, err := ContainerInspect(c, cli)
+		c.CheckStatics(j, err)
+	}
+}
+
+// CheckEvent runs the event through every container it belongs to and sends the alert
+func CheckEvent(cnt *[]AlertdContainer, m events.Message, cli *client.Client) {
+	checkMu.Lock()
+	defer checkMu.Unlock()
+
+	a := &Alert{Messages: []error{}}
+	for i := range *cnt {
+		c := &(*cnt)[i]
+		if !c.MatchesEvent(m) {
+			continue
+		}
+
+		c.Alert.Clear()
+		c.HandleEvent(m, cli)
+		a.Concat(c.Alert)
+		c.Alert.Clear()
+	}
+
+	a.Evaluate()
+	stateStore.Save(*cnt)
+	dashboard.Update(*cnt)
+	snapshot.Update(*cnt)
+}
+
+// CheckAllStatics runs the static checks on all of the containers, it is used to catch up on
+// anything that was missed while the events stream was disconnected
+func CheckAllStatics(cnt *[]AlertdContainer, cli *client.Client) {
+	checkMu.Lock()
+	defer checkMu.Unlock()
+
+	a := &Alert{Messages: []error{}}
+	for i := range *cnt {
+		c := &(*cnt)[i]
+
+		c.Alert.Clear()
+		j, err := ContainerInspect(c, cli)
+		c.CheckStatics(j, err)
+		a.Concat(c.Alert)
+		c.Alert.Clear()
+	}
+
+	a.Evaluate()
+	stateStore.Save(*cnt)
+	dashboard.Update(*cnt)
+	snapshot.Update(*cnt)
+}
+
+// WatchEvents subscribes to the docker events stream and feeds the container events into the
+// checks until the context is cancelled, it reconnects when the stream fails. cnt is a
+// pointer because the monitor loop replaces the slice when selectors are resolved.
+func WatchEvents(ctx context.Context, cnt *[]AlertdContainer, cli *client.Client) {
+	f := filters.NewArgs()
+	f.Add("type", "container")
+	for _, e := range watchedEvents {
+		f.Add("event", e)
+	}
+
+	for {
+		msgs, errs := cli.Events(ctx, types.EventsOptions{Filters: f})
+
+	stream:
+		for {
+			select {
+			case m := <-msgs:
+				CheckEvent(cnt, m, cli)
+			case err := <-errs:
+				if ctx.Err() != nil {
+					return
+				}
+				log.Println(errors.Wrap(err, "docker events stream"))
+				break stream
+			}
+		}
+
+		time.Sleep(time.Second)
+		CheckAllStatics(cnt, cli)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/pkg/errors"
+)
+
+func TestHandleDieEvent(t *testing.T) {
+	tests := []struct {
+		Name          string
+		Events        []events.Message
+		ExpectedAlert error
+		ExpectedMatch string
+	}{
+		{
+			Name: "die event fails running check",
+			Events: []events.Message{
+				{
+					Action: "die",
+					Actor: events.Actor{
+						ID:         "0123456789ab",
+						Attributes: map[string]string{"name": "test", "exitCode": "1"},
+					},
+				},
+			},
+			ExpectedAlert: ErrRunningCheckFail,
+			ExpectedMatch: "exit code: 1, oom killed: false",
+		},
+		{
+			Name: "oom and kill events are included in the die alert",
+			Events: []events.Message{
+				{
+					Action: "oom",
+					Actor:  events.Actor{Attributes: map[string]string{"name": "test"}},
+				},
+				{
+					Action: "kill",
+					Actor: events.Actor{
+						Attributes: map[string]string{"name": "test", "signal": "9"},
+					},
+				},
+				{
+					Action: "die",
+					Actor: events.Actor{
+						Attributes: map[string]string{"name": "test", "exitCode": "137"},
+					},
+				},
+			},
+			ExpectedAlert: ErrRunningCheckFail,
+			ExpectedMatch: "exit code: 137, oom killed: true, error: killed with signal 9",
+		},
+		{
+			Name: "die event with accepted exit code does not alert",
+			Events: []events.Message{
+				{
+					Action: "die",
+					Actor: events.Actor{
+						Attributes: map[string]string{"name": "test", "exitCode": "0"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cnt := InitCheckers(&Conf{
+			Containers: []Container{
+				{
+					Name:              "test",
+					ExpectedRunning:   boolP(true),
+					AcceptedExitCodes: []int{0},
+				},
+			},
+		})
+		c := &cnt[0]
+
+		for _, m := range test.Events {
+			if !c.MatchesEvent(m) {
+				t.Errorf("%s: event %s does not match container", test.Name, m.Action)
+			}
+			c.HandleEvent(m, nil)
+		}
+
+		switch {
+		case test.ExpectedAlert == nil && c.Alert.ShouldSend():
+			t.Errorf("%s: expected no alert, got: %v", test.Name, c.Alert.Messages)
+		case test.ExpectedAlert != nil && !CheckHasErr(c.Alert.Messages, test.ExpectedAlert):
+			t.Errorf("%s: expected error message: %s not found in error messages: %v",
+				test.Name, test.ExpectedAlert, c.Alert.Messages)
+		case test.ExpectedMatch != "" && !ErrContainsErr(c.Alert.Messages[0],
+			errors.New(test.ExpectedMatch)):
+			t.Errorf("%s: expected %q in %v", test.Name, test.ExpectedMatch, c.Alert.Messages)
+		}
+	}
+}
+
+func TestMatchesEvent(t *testing.T) {
+	id := "deadbeef0123456789abcdef0123456789abcdef0123456789abcdef01234567"
+	m := events.Message{
+		Action: "die",
+		Actor:  events.Actor{ID: id, Attributes: map[string]string{"name": "other"}},
+	}
+
+	tests := []struct {
+		Name     string
+		ID       string
+		Expected bool
+	}{
+		{Name: "other", Expected: true},
+		{Name: "dead", Expected: false},         // a name that looks like hex
+		{Name: "deadbeef", Expected: false},     // still shorter than a short ID
+		{Name: "deadbeef0123", Expected: true},  // short ID
+		{Name: "deadbeef0124", Expected: false}, // another short ID
+		{Name: "web", ID: id, Expected: true},   // renamed, matched by the resolved ID
+		{Name: "dead", ID: "ab12", Expected: false},
+	}
+
+	for _, test := range tests {
+		c := NewAlertdContainer(Container{}, test.Name)
+		c.ID = test.ID
+		if c.MatchesEvent(m) != test.Expected {
+			t.Errorf("%s (%s): expected match %t", test.Name, test.ID, test.Expected)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// historyStore records the alert history, it is nil when no history file is configured
+var historyStore *HistoryStore
+
+// HistoryRecord is an alert message in the history, Alerters are the alerters that sent it
+// successfully. Silenced messages are recorded without being sent.
+type HistoryRecord struct {
+	Time      time.Time `json:"time"`
+	Container string    `json:"container"`
+	Check     string    `json:"check"`
+	Severity  Severity  `json:"severity"`
+	Value     *uint64   `json:"value,omitempty"`
+	Limit     *uint64   `json:"limit,omitempty"`
+	Message   string    `json:"message"`
+	Alerters  []string  `json:"alerters"`
+	Silenced  bool      `json:"silenced,omitempty"`
+
+	// route is the route of the message, to know which alerters it was sent to
+	route []string
+}
+
+// HistoryRecords returns the history records of the alert messages
+func (a *Alert) HistoryRecords(now time.Time) []HistoryRecord {
+	records := []HistoryRecord{}
+	for i, msg := range a.Messages {
+		t := a.TransitionOf(i)
+		records = append(records, HistoryRecord{
+			Time:      now,
+			Container: t.Container,
+			Check:     t.Check,
+			Severity:  a.SeverityOf(i),
+			Value:     t.Value,
+			Limit:     t.Limit,
+			Message:   msg.Error(),
+			Alerters:  []string{},
+			route:     a.RouteOf(i),
+		})
+	}
+	return records
+}
+
+// HistoryTimeout is how long the records wait for the alerters by default, it is longer
+// than AlertTimeout because some alerters send a request for every message
+const HistoryTimeout = time.Minute
+
+// HistoryStore appends the alert history to a file with one JSON record per line, Timeout
+// is how long the records wait for the alerters (HistoryTimeout when it is 0)
+type HistoryStore struct {
+	Path    string
+	Timeout time.Duration
+	mu      sync.Mutex
+	pending sync.WaitGroup
+}
+
+// Record waits in the background for the n alerters to send the records, each of them
+// sends its name on the channel when it succeeded (or an empty string when it failed), and
+// then appends the records to the history file. The records are written with the alerters
+// that are done once the timeout is up.
+func (s *HistoryStore) Record(records []HistoryRecord, sent <-chan string, n int) {
+	if s == nil {
+		return
+	}
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.record(records, sent, n)
+	}()
+}
+
+// record does the work of Record
+func (s *HistoryStore) record(records []HistoryRecord, sent <-chan string, n int) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = HistoryTimeout
+	}
+	expired := time.After(timeout)
+
+wait:
+	for ; n > 0; n-- {
+		select {
+		case name := <-sent:
+			if name == "" {
+				continue
+			}
+
+			for i := range records {
+				if len(records[i].route) == 0 || containsString(records[i].route, name) {
+					records[i].Alerters = append(records[i].Alerters, name)
+				}
+			}
+		case <-expired:
+			log.Printf("recording the alert history without the %d alerters that are not "+
+				"done after %s", n, timeout)
+			break wait
+		}
+	}
+
+	if err := s.Append(records); err != nil {
+		log.Println(err)
+	}
+}
+
+// Flush waits for the records that are waiting on the alerters to be written
+func (s *HistoryStore) Flush() {
+	if s == nil {
+		return
+	}
+	s.pending.Wait()
+}
+
+// Append writes the records to the end of the history file
+func (s *HistoryStore) Append(records []HistoryRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening history file")
+	}
+	defer f.Close()
+
+	e := json.NewEncoder(f)
+	for _, r := range records {
+		if err := e.Encode(r); err != nil {
+			return errors.Wrap(err, "writing history file")
+		}
+	}
+	return nil
+}
+
+// ReadHistory reads all of the records in the history file
+func ReadHistory(path string) ([]HistoryRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening history file")
+	}
+	defer f.Close()
+
+	records := []HistoryRecord{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var r HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, errors.Wrap(err, "parsing history file")
+		}
+		records = append(records, r)
+	}
+	return records, errors.Wrap(scanner.Err(), "reading history file")
+}
+
+// HistoryFilter selects the history records by container, check and time range, the
+// fields which are empty select everything
+type HistoryFilter struct {
+	Container string
+	Check     string
+	Since     time.Time
+	Until     time.Time
+}
+
+// Matches returns true if the record is selected by the filter
+func (f HistoryFilter) Matches(r HistoryRecord) bool {
+	switch {
+	case f.Container != "" && f.Container != r.Container:
+		return false
+	case f.Check != "" && !strings.EqualFold(f.Check, r.Check):
+		return false
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && r.Time.After(f.Until):
+		return false
+	default:
+		return true
+	}
+}
+
+// FilterHistory returns the records that are selected by the filter
+func FilterHistory(records []HistoryRecord, f HistoryFilter) []HistoryRecord {
+	filtered := []HistoryRecord{}
+	for _, r := range records {
+		if f.Matches(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// ParseHistoryTime parses a time given either as RFC3339 or as a duration before now (24h)
+func ParseHistoryTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, errors.Wrap(err, "time must be RFC3339 or a duration")
+}
+
+// uintString returns the number as a string, or an empty string when it is not set
+func uintString(u *uint64) string {
+	if u == nil {
+		return ""
+	}
+	return strconv.FormatUint(*u, 10)
+}
+
+// WriteHistory writes the records to w in the output format, which is one of text, json or
+// csv
+func WriteHistory(w io.Writer, records []HistoryRecord, output string) error {
+	switch output {
+	case "json":
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(records)
+
+	case "csv":
+		c := csv.NewWriter(w)
+		c.Write([]string{"time", "container", "check", "severity", "value", "limit",
+			"alerters", "message"})
+		for _, r := range records {
+			c.Write([]string{r.Time.Format(time.RFC3339), r.Container, r.Check,
+				r.Severity.String(), uintString(r.Value), uintString(r.Limit),
+				strings.Join(r.Alerters, ";"), r.Message})
+		}
+		c.Flush()
+		return c.Error()
+
+	case "text", "":
+		for _, r := range records {
+			fmt.Fprintf(w, "%s [%s] %s (sent to: %s)\n", r.Time.Format(time.RFC3339),
+				r.Severity, r.Message, strings.Join(r.Alerters, ", "))
+		}
+		return nil
+
+	default:
+		return errors.Errorf("unknown output format: %s", output)
+	}
+}
+
+// historyCmd shows the alert history
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "show the alerts that were sent",
+	Long: `Show the alerts recorded in the history file, filtered by container, check and
+time range. Times are RFC3339 or a duration before now (24h).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		f := HistoryFilter{Container: historyContainer, Check: historyCheck}
+
+		var err error
+		f.Since, err = ParseHistoryTime(historySince, now)
+		if err == nil {
+			f.Until, err = ParseHistoryTime(historyUntil, now)
+		}
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+		if Config.HistoryFile == "" {
+			log.Println("no history file, set historyFile in the config or --history")
+			os.Exit(1)
+		}
+
+		records, err := ReadHistory(Config.HistoryFile)
+		if err == nil {
+			err = WriteHistory(os.Stdout, FilterHistory(records, f), historyOutput)
+		}
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var (
+	historyContainer string
+	historyCheck     string
+	historySince     string
+	historyUntil     string
+	historyOutput    string
+)
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringVar(&historyContainer, "container", "",
+		"only show the alerts of the container")
+	historyCmd.Flags().StringVar(&historyCheck, "check", "",
+		"only show the alerts of the check (maxCpu, running, health...)")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only show the alerts since")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "only show the alerts until")
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", "text",
+		"output format: text, json or csv")
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistoryRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90), Alerters: []string{"slack"}}, "test")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+
+	a := &Alert{Messages: []error{}}
+	a.Concat(c.Alert)
+	a.Add(ErrUnknown, nil, "other", "") // not routed, sent to every alerter
+
+	sent := make(chan string, 3)
+	sent <- "slack"
+	sent <- ""
+	sent <- "email"
+
+	s := &HistoryStore{Path: filepath.Join(dir, "history")}
+	s.Record(a.HistoryRecords(time.Now()), sent, 3)
+	s.Flush()
+
+	records, err := ReadHistory(s.Path)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case len(records) != 2:
+		t.Fatalf("expected 2 records, got: %d", len(records))
+	}
+
+	r := records[0]
+	switch {
+	case r.Container != "test" || r.Check != "maxCpu" || r.Severity != SeverityCritical:
+		t.Errorf("unexpected record: %+v", r)
+	case r.Value == nil || *r.Value != 95 || r.Limit == nil || *r.Limit != 90:
+		t.Errorf("expected the value and limit to be recorded: %+v", r)
+	case strings.Join(r.Alerters, ",") != "slack":
+		t.Errorf("expected the record to be sent to slack, got: %s", r.Alerters)
+	case strings.Join(records[1].Alerters, ",") != "slack,email":
+		t.Errorf("expected the record to be sent to slack and email, got: %s",
+			records[1].Alerters)
+	}
+
+	// an alerter that never finishes does not keep the records from being written
+	hung := make(chan string, 2)
+	hung <- "slack"
+	s.Timeout = 50 * time.Millisecond
+	s.Record(a.HistoryRecords(time.Now()), hung, 2)
+	s.Flush()
+
+	records, err = ReadHistory(s.Path)
+	switch {
+	case err != nil:
+		t.Fatal(err)
+	case len(records) != 4:
+		t.Fatalf("expected the records to be written after the timeout, got: %d", len(records))
+	case strings.Join(records[3].Alerters, ",") != "slack":
+		t.Errorf("expected only the alerter that finished, got: %s", records[3].Alerters)
+	}
+}
+
+func TestFilterHistory(t *testing.T) {
+	now := time.Now()
+	records := []HistoryRecord{
+		{Time: now.Add(-48 * time.Hour), Container: "web", Check: "maxCpu"},
+		{Time: now.Add(-time.Hour), Container: "web", Check: "running"},
+		{Time: now.Add(-time.Hour), Container: "db", Check: "maxCpu"},
+	}
+
+	since, err := ParseHistoryTime("24h", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Filter   HistoryFilter
+		Expected int
+	}{
+		{Filter: HistoryFilter{}, Expected: 3},
+		{Filter: HistoryFilter{Container: "web"}, Expected: 2},
+		{Filter: HistoryFilter{Check: "maxcpu"}, Expected: 2},
+		{Filter: HistoryFilter{Since: since}, Expected: 2},
+		{Filter: HistoryFilter{Container: "web", Until: since}, Expected: 1},
+	}
+
+	for i, test := range tests {
+		if n := len(FilterHistory(records, test.Filter)); n != test.Expected {
+			t.Errorf("filter %d: expected: %d records, got: %d", i, test.Expected, n)
+		}
+	}
+
+	b := &bytes.Buffer{}
+	if err := WriteHistory(b, records[:1], "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(b.String()), "\n"); len(lines) != 2 {
+		t.Errorf("expected a csv header and a record, got: %s", b.String())
+	}
+}
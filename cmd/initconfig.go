@@ -26,6 +26,38 @@ var (
 			ShouldPrint: false,
 			Bytes:       pushover,
 		},
+		"webhook": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       webhook,
+		},
+		"pagerduty": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       pagerduty,
+		},
+		"opsgenie": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       opsgenie,
+		},
+		"teams": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       teams,
+		},
+		"googlechat": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       googlechat,
+		},
+		"discord": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       discord,
+		},
+		"mattermost": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       mattermost,
+		},
+		"rocketchat": &AlerterStub{
+			ShouldPrint: false,
+			Bytes:       rocketchat,
+		},
 	}
 )
 
@@ -106,6 +138,22 @@ func init() {
 	initconfigCmd.Flags().BoolVar(&alerterStubs["slack"].ShouldPrint, "slack", false, "include slack alert stub")
 	initconfigCmd.Flags().BoolVar(&alerterStubs["pushover"].ShouldPrint, "pushover", false,
 		"include pushover alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["webhook"].ShouldPrint, "webhook", false,
+		"include webhook alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["pagerduty"].ShouldPrint, "pagerduty", false,
+		"include pagerduty alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["opsgenie"].ShouldPrint, "opsgenie", false,
+		"include opsgenie alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["teams"].ShouldPrint, "teams", false,
+		"include teams alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["googlechat"].ShouldPrint, "googlechat", false,
+		"include google chat alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["discord"].ShouldPrint, "discord", false,
+		"include discord alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["mattermost"].ShouldPrint, "mattermost", false,
+		"include mattermost alert stub")
+	initconfigCmd.Flags().BoolVar(&alerterStubs["rocketchat"].ShouldPrint, "rocketchat", false,
+		"include rocket.chat alert stub")
 	initconfigCmd.Flags().BoolVar(&stdout, "stdout", false, "print config to stdout")
 
 }
@@ -120,6 +168,22 @@ func shouldPrintall() bool {
 		return false
 	case alerterStubs["pushover"].ShouldPrint:
 		return false
+	case alerterStubs["webhook"].ShouldPrint:
+		return false
+	case alerterStubs["pagerduty"].ShouldPrint:
+		return false
+	case alerterStubs["opsgenie"].ShouldPrint:
+		return false
+	case alerterStubs["teams"].ShouldPrint:
+		return false
+	case alerterStubs["googlechat"].ShouldPrint:
+		return false
+	case alerterStubs["discord"].ShouldPrint:
+		return false
+	case alerterStubs["mattermost"].ShouldPrint:
+		return false
+	case alerterStubs["rocketchat"].ShouldPrint:
+		return false
 	default:
 		return true
 	}
@@ -131,6 +195,56 @@ var config = []byte(`---
 #duration: 100				# duration in ms between docker API calls
 #iterations: 0				# number of iterations to run (0 = run forever)
 
+# In events mode the docker events stream (die, oom, kill, health_status, destroy...) is
+# used for the existence, running, health and restart checks so that containers which die
+# and restart between polls are caught. Polling is then only used for resource metrics and
+# to recover maxRestarts and alert on maxHealthStarting, which change without an event.
+#events: true
+
+# In discovery mode the container settings are also read from labels on the containers
+# themselves, e.g. "alertd.maxCpu=80", "alertd.expectedRunning=true", "alertd.alerters=slack".
+# Labeled containers that are not in this file are monitored automatically, the labels of
+# containers that are in this file take precedence over the settings here.
+#discovery: true
+#discoveryPrefix: alertd
+
+# The state of the checks is written to the state file whenever it changes and is read at
+# startup, so that restarting docker-alertd does not send active alerts again.
+#stateFile: /var/lib/docker-alertd/state.json
+
+# Every alert is appended to the history file, it can be read with "docker-alertd history".
+#historyFile: /var/lib/docker-alertd/history
+
+# An HTTP server that serves prometheus metrics on /metrics, the values of the checks, their
+# limits and alert states, the alerts sent by each alerter and the monitor loop latency.
+# It also serves the state of the containers and their checks as JSON on /api/containers
+# and the health of docker-alertd on /healthz. A live web dashboard is served on /.
+#listen: :9102
+
+# Silences keep the alerts of containers (a name or glob, all containers when omitted) and
+# checks (all checks when omitted) from being sent between the start and end times. The
+# containers can also be selected with nameRegex, label, image, composeProject and
+# composeService like the containers below. The alerts are still logged and recorded in the
+# history, and the checks which are not back in the state they were last sent with alert
+# once the silence ends. Silences can also be
+# added with the "docker-alertd silence" command and the /api/silences HTTP endpoint, those
+# are kept in the silence file. Maintenance windows are recurring silences, they start at
+# every time of the cron schedule ("minute hour day-of-month month day-of-week") and last
+# duration seconds. Adding and removing silences over HTTP needs the silenceToken as an
+# "Authorization: Bearer <token>" header, without it the silences can only be listed.
+#silenceFile: /var/lib/docker-alertd/silences.json
+#silenceToken: changeme
+#silences:
+#  - container: web-*
+#    check: running
+#    start: "2017-09-18T22:00:00Z"
+#    end: "2017-09-19T02:00:00Z"
+#    comment: database migration
+#maintenance:
+#  - composeService: backup
+#    schedule: "0 3 * * 0"
+#    duration: 3600
+
 # 'containers' is an array of dictionaries that each contain the name of a container to
 # monitor, and the metrics which it should be monitored by. If there are no metrics
 # present, then it will just be monitored to make sure that is is currently up.
@@ -143,11 +257,55 @@ containers:
   - name: container1
     expectedRunning: true
 
+  - name: batchjob
+    expectedRunning: true
+    acceptedExitCodes: [0]      # exiting with these codes does not trigger an alert
+
   - name: container2
     expectedRunning: true
+    expectedHealthy: true       # alert when the docker HEALTHCHECK is unhealthy
+    maxHealthStarting: 120      # seconds the health can be "starting" before alerting
+    maxRestarts: 3              # alert on more than 3 restarts...
+    restartWindow: 300          # ...within 300 seconds (default 300)
     maxCpu: 20
-    maxMem: 20
+    maxMem: 20            # usage without the page cache, like docker stats shows
+    memUnit: MiB          # unit for maxMem: MB (default), MiB or GiB
+    maxMemPercent: 90     # percent of the container's memory limit
     minProcs: 4
+    maxProcs: 200
+    maxNetRx: 1000000     # bytes/sec received
+    maxNetTx: 1000000     # bytes/sec sent
+    maxNetErrors: 10      # dropped/errored packets/sec
+    maxBlkioRead: 50000000      # disk read bytes/sec
+    maxBlkioWrite: 50000000     # disk write bytes/sec
+    maxBlkioReadOps: 1000       # disk read operations/sec
+    maxBlkioWriteOps: 1000      # disk write operations/sec
+    # checks has settings per metric check, keyed by the name of the setting above.
+    # samples/seconds: the limit has to be breached for this many consecutive samples
+    # and this many seconds before alerting, and be healthy as long before recovering.
+    # warning: a lower level (higher for minProcs) that sends a warning, the limit of the
+    # check is the critical level.
+    # recover: the level an active alert recovers at (under the warning and the limit).
+    # flapTransitions/flapSeconds: after this many state changes within this many seconds
+    # (default 600) the check is flapping, one notice is sent and its alerts are suppressed
+    # until it settles.
+    checks:
+      maxCpu:
+        samples: 5
+        seconds: 30
+        warning: 15
+        recover: 10
+        flapTransitions: 6
+
+  # Instead of a name, containers can be selected with nameRegex, nameGlob, label (key=value
+  # or key), image, composeProject and composeService. Every selector that is set has to
+  # match. The containers are re-resolved on every loop so scaled replicas are picked up and
+  # containers that go away stop being monitored.
+  - composeProject: myapp
+    composeService: worker
+    expectedRunning: true
+    maxCpu: 80
+    #alerters: [slack]          # only send alerts of these containers to these alerters
 
 ## ALERTERS...
 ## If any of the below alerters are present, alerts will be sent through the proper 
@@ -181,3 +339,82 @@ pushover:
   ApiToken: your_api_token
   UserKey: your_user_key
 `)
+
+var webhook = []byte(`
+# Sends the alerts to any HTTP endpoint. The body is rendered with the Go text/template
+# template from the alert: .Time, .Severity, .Subject and .Messages, each message has
+# .Container, .ID, .Image, .Check, .Severity, .Value, .Limit and .Message. "json" quotes a
+# value for a JSON payload. The body is the alert as JSON when there is no template.
+webhook:
+  url: https://incidents.example.com/api/events
+  method: POST
+  headers:
+    Authorization: Bearer your_token
+  template: |
+    {
+      "severity": {{json .Severity}},
+      "summary": {{json .Subject}},
+      "events": [{{range $i, $m := .Messages}}{{if $i}},{{end}}
+        {"host": {{json $m.Container}}, "check": {{json $m.Check}}, "text": {{json $m.Message}}}{{end}}
+      ]
+    }
+
+# More webhooks can be listed with an id each, containers route their alerts to them with
+# "webhook:<id>" in their alerters.
+#webhooks:
+#  - id: audit
+#    url: https://audit.example.com/alerts
+`)
+
+var pagerduty = []byte(`
+# Triggers a PagerDuty incident when a check fails and resolves it when the check recovers.
+# The routing key is the integration key of an Events API v2 integration on a service.
+pagerDuty:
+  routingKey: your_integration_key
+`)
+
+var opsgenie = []byte(`
+# Creates an Opsgenie alert when a check fails and closes it when the check recovers. The
+# api key is the key of an API integration, accounts in the EU need the url below.
+opsgenie:
+  apiKey: your_api_key
+  #url: https://api.eu.opsgenie.com
+  tags: [docker]
+`)
+
+var teams = []byte(`
+# Posts a card for every alert to a Microsoft Teams channel, the webhookURL is the url of
+# a workflow ("Post to a channel when a webhook request is received") or incoming webhook
+teams:
+  webhookURL: https://some.url/provided/by/teams/
+`)
+
+var googlechat = []byte(`
+# Posts a card for every alert to a Google Chat space, see "Apps & integrations" >
+# "Webhooks" in the settings of the space for the webhookURL
+googleChat:
+  webhookURL: https://chat.googleapis.com/v1/spaces/your_space/messages?key=your_key
+`)
+
+var discord = []byte(`
+# Posts the alerts as embeds to a Discord channel, see "Integrations" > "Webhooks" in the
+# settings of the channel for the webhookURL
+discord:
+  webhookURL: https://discord.com/api/webhooks/your_id/your_token
+`)
+
+var mattermost = []byte(`
+# Posts the alerts as attachments to a Mattermost incoming webhook, channel is optional and
+# overrides the channel of the webhook
+mattermost:
+  webhookURL: https://mattermost.example.com/hooks/your_key
+  #channel: alerts
+`)
+
+var rocketchat = []byte(`
+# Posts the alerts as attachments to a Rocket.Chat incoming webhook integration, channel is
+# optional and overrides the channel of the integration
+rocketChat:
+  webhookURL: https://rocket.example.com/hooks/your_id/your_token
+  #channel: "#alerts"
+`)
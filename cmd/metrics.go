@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the metrics of docker-alertd itself, the metrics of the containers are read from the
+// checks when they are scraped
+var (
+	alertsSent   = NewCounterVec()
+	alertErrors  = NewCounterVec()
+	pollDuration = NewHistogram([]float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30})
+)
+
+// CounterVec is a set of counters by label value
+type CounterVec struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// NewCounterVec returns an empty set of counters
+func NewCounterVec() *CounterVec {
+	return &CounterVec{values: map[string]uint64{}}
+}
+
+// Inc adds one to the counter of the label value
+func (c *CounterVec) Inc(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[label]++
+}
+
+// Write writes the counters in the prometheus text format
+func (c *CounterVec) Write(w io.Writer, name, help, label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, k := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", name, label, labelValue(k), c.values[k])
+	}
+}
+
+// Histogram counts observations into buckets with the upper bounds
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram returns an empty histogram with the bucket upper bounds, in increasing order
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// Observe adds the value to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Write writes the histogram in the prometheus text format
+func (h *Histogram) Write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, b, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, h.sum, name, h.count)
+}
+
+// ObservePoll records how long a monitor iteration took
+func ObservePoll(start time.Time) {
+	pollDuration.Observe(time.Since(start).Seconds())
+}
+
+// AlertSent counts an alert sent by the alerter, or an alerter error when err is not nil
+func AlertSent(name string, err error) {
+	if err != nil {
+		alertErrors.Inc(name)
+		return
+	}
+	alertsSent.Inc(name)
+}
+
+// labelValue quotes the label value for the prometheus text format
+func labelValue(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// sortedKeys returns the keys of the map in order so that the metrics are written in a
+// stable order
+func sortedKeys(m map[string]uint64) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// boolGauge returns the gauge value of the bool
+func boolGauge(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WriteContainerMetrics writes the metrics of the containers in the prometheus text format.
+// The values are the same ones the metric checks compare to their limits, they are written
+// for the checks without a limit too.
+func WriteContainerMetrics(w io.Writer, cnt []AlertdContainer) {
+	type sample struct {
+		Labels string
+		Value  string
+	}
+	values, limits, active, severity := []sample{}, []sample{}, []sample{}, []sample{}
+
+	for i := range cnt {
+		c := &cnt[i]
+
+		metrics := c.MetricChecks()
+		names := []string{}
+		for k, m := range metrics {
+			if m != nil && (m.Limit != nil || m.Value != nil) {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+
+		for _, k := range names {
+			m := metrics[k]
+			l := fmt.Sprintf("container=%s,check=%s", labelValue(c.Name), labelValue(k))
+
+			if m.Value != nil {
+				values = append(values, sample{l, fmt.Sprint(*m.Value)})
+			}
+			if m.Limit == nil {
+				continue // only the value is sampled
+			}
+			limits = append(limits, sample{l, fmt.Sprint(*m.Limit)})
+			active = append(active, sample{l, fmt.Sprint(boolGauge(m.AlertActive))})
+			severity = append(severity, sample{l, fmt.Sprint(int(m.Severity()))})
+		}
+
+		statics := c.StaticChecks()
+		for _, k := range []string{"existence", "running", "health"} {
+			if s := statics[k]; s != nil {
+				l := fmt.Sprintf("container=%s,check=%s", labelValue(c.Name), labelValue(k))
+				active = append(active, sample{l, fmt.Sprint(boolGauge(s.AlertActive))})
+			}
+		}
+	}
+
+	for _, g := range []struct {
+		Name, Help string
+		Samples    []sample
+	}{
+		{"docker_alertd_check_value", "The last value of the metric check.", values},
+		{"docker_alertd_check_limit", "The limit of the metric check.", limits},
+		{"docker_alertd_check_alert_active", "1 if the alert of the check is active.", active},
+		{"docker_alertd_check_severity", "The severity of the check, 0 ok, 1 warning, " +
+			"2 critical.", severity},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.Name, g.Help, g.Name)
+		for _, s := range g.Samples {
+			fmt.Fprintf(w, "%s{%s} %s\n", g.Name, s.Labels, s.Value)
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP docker_alertd_containers The number of monitored containers.\n"+
+		"# TYPE docker_alertd_containers gauge\ndocker_alertd_containers %d\n", len(cnt))
+}
+
+// WriteAlertdMetrics writes the metrics of docker-alertd itself in the prometheus text format
+func WriteAlertdMetrics(w io.Writer) {
+	alertsSent.Write(w, "docker_alertd_alerts_sent_total",
+		"The number of alerts sent by each alerter.", "alerter")
+	alertErrors.Write(w, "docker_alertd_alert_errors_total",
+		"The number of alerts that failed to send by each alerter.", "alerter")
+	pollDuration.Write(w, "docker_alertd_poll_duration_seconds",
+		"How long each monitor iteration took.")
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90), ExpectedRunning: boolP(true)},
+		"test")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	c.CheckMemory(&types.Stats{MemoryStats: types.MemoryStats{Usage: 2000000}}) // no limit
+	cnt := []AlertdContainer{c}
+
+	AlertSent("slack", nil)
+	AlertSent("email", errors.New("smtp error"))
+
+	defer func(s *Snapshot) { snapshot = s }(snapshot)
+	snapshot = &Snapshot{}
+	snapshot.Update(cnt)
+
+	// a poll that is waiting on the docker API holds checkMu, it must not block the scrape
+	checkMu.Lock()
+	defer checkMu.Unlock()
+
+	rec := httptest.NewRecorder()
+	NewServeMux().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+
+	for _, line := range []string{
+		`docker_alertd_check_value{container="test",check="maxCpu"} 95`,
+		`docker_alertd_check_limit{container="test",check="maxCpu"} 90`,
+		`docker_alertd_check_alert_active{container="test",check="maxCpu"} 1`,
+		`docker_alertd_check_alert_active{container="test",check="running"} 0`,
+		`docker_alertd_check_severity{container="test",check="maxCpu"} 2`,
+		`docker_alertd_check_value{container="test",check="maxMem"} 2`,
+		`docker_alertd_alerts_sent_total{alerter="slack"}`,
+		`docker_alertd_alert_errors_total{alerter="email"}`,
+		`docker_alertd_poll_duration_seconds_bucket{le="+Inf"}`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected the metrics to have: %s", line)
+		}
+	}
+
+	if strings.Contains(out, `docker_alertd_check_limit{container="test",check="maxMem"}`) {
+		t.Errorf("expected no limit for the check without one")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{1, 5})
+	for _, v := range []float64{0.5, 2, 10} {
+		h.Observe(v)
+	}
+
+	b := &bytes.Buffer{}
+	h.Write(b, "test", "help")
+	for _, line := range []string{`test_bucket{le="1"} 1`, `test_bucket{le="5"} 2`,
+		`test_bucket{le="+Inf"} 3`, `test_sum 12.5`, `test_count 3`} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("expected the histogram to have: %s, got: %s", line, b.String())
+		}
+	}
+}
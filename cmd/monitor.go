@@ -8,6 +8,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
 )
 
 func uint64P(u uint64) *uint64 {
@@ -29,7 +30,7 @@ func boolP(b bool) *bool {
 
 // GetStats just uses the docker API and an already tested Unmarshal function, no
 // testing needed.
-func GetStats(a *AlertdContainer, c *client.Client) (*types.Stats, error) {
+func GetStats(a *AlertdContainer, c *client.Client) (*types.StatsJSON, error) {
 	cs, err := c.ContainerStats(context.Background(), a.Name, false)
 	if err != nil {
 		return nil, err
@@ -39,7 +40,7 @@ func GetStats(a *AlertdContainer, c *client.Client) (*types.Stats, error) {
 	d := json.NewDecoder(cs.Body)
 	d.UseNumber()
 
-	var stats types.Stats
+	var stats types.StatsJSON
 	if err := d.Decode(&stats); err != nil {
 		return nil, err
 	}
@@ -55,64 +56,146 @@ func ContainerInspect(a *AlertdContainer, c *client.Client) (*types.ContainerJSO
 		return nil, err
 	}
 
+	// the selectors and discovery keep track of their containers by ID, so it is only
+	// updated for the containers that are monitored by name
+	if a.Selector == nil && !a.Discovered && containerJSON.ContainerJSONBase != nil {
+		a.ID = containerJSON.ID
+	}
+	if containerJSON.Config != nil {
+		a.Image = containerJSON.Config.Image
+		a.Labels = containerJSON.Config.Labels
+	}
+
 	return &containerJSON, nil
 }
 
+// ListContainers returns all of the containers, running or not, for the selectors and
+// discovery
+func ListContainers(c *client.Client) ([]types.Container, error) {
+	list, err := c.ContainerList(context.Background(), types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing containers")
+	}
+	return list, nil
+}
+
 // InitCheckers returns a slice of containers with all the info needed to run a
-// check on the container. Active is for whether or not the alert is active, not the check
+// check on the container. Active is for whether or not the alert is active, not the check.
+// Containers which are given by a selector are added later by ResolveSelectors.
 func InitCheckers(c *Conf) []AlertdContainer {
 	// Taking the values from the conf and adding them into the AlertdContainers
 	var containers []AlertdContainer
 	for _, v := range c.Containers {
-		containers = append(containers, AlertdContainer{
-			Name: v.Name,
-			Alert: &Alert{
-				Messages: []error{},
-			},
-			CPUCheck: &MetricCheck{
-				Limit:       v.MaxCPU,
-				AlertActive: false,
-			},
-			MemCheck: &MetricCheck{
-				Limit:       v.MaxMem,
-				AlertActive: false,
-			},
-			PIDCheck: &MetricCheck{
-				Limit:       v.MinProcs,
-				AlertActive: false,
-			},
-			ExistenceCheck: &StaticCheck{
-				Expected:    boolP(true),
-				AlertActive: false,
-			},
-			RunningCheck: &StaticCheck{
-				Expected:    v.ExpectedRunning,
-				AlertActive: false,
-			},
-		})
+		if v.IsSelector() {
+			continue
+		}
+		containers = append(containers, NewAlertdContainer(v, v.Name))
 	}
 	return containers
 }
 
+// NewAlertdContainer returns the checker for the container with the given name, with all of
+// the checks set up from the container settings in the config
+func NewAlertdContainer(v Container, name string) AlertdContainer {
+	return AlertdContainer{
+		Name: name,
+		Alert: &Alert{
+			Messages: []error{},
+			Route:    v.Alerters,
+		},
+		CPUCheck:           NewMetricCheck(v.MaxCPU, v.Settings("maxCpu")),
+		MemCheck:           NewMetricCheck(v.MaxMem, v.Settings("maxMem")),
+		MemUnit:            v.MemUnit,
+		MemPercentCheck:    NewMetricCheck(v.MaxMemPercent, v.Settings("maxMemPercent")),
+		PIDCheck:           NewMinMetricCheck(v.MinProcs, v.Settings("minProcs")),
+		MaxPIDCheck:        NewMetricCheck(v.MaxProcs, v.Settings("maxProcs")),
+		NetRxCheck:         NewMetricCheck(v.MaxNetRx, v.Settings("maxNetRx")),
+		NetTxCheck:         NewMetricCheck(v.MaxNetTx, v.Settings("maxNetTx")),
+		NetErrCheck:        NewMetricCheck(v.MaxNetErrors, v.Settings("maxNetErrors")),
+		BlkioReadCheck:     NewMetricCheck(v.MaxBlkioRead, v.Settings("maxBlkioRead")),
+		BlkioWriteCheck:    NewMetricCheck(v.MaxBlkioWrite, v.Settings("maxBlkioWrite")),
+		BlkioReadOpsCheck:  NewMetricCheck(v.MaxBlkioReadOps, v.Settings("maxBlkioReadOps")),
+		BlkioWriteOpsCheck: NewMetricCheck(v.MaxBlkioWriteOps, v.Settings("maxBlkioWriteOps")),
+		ExistenceCheck: &StaticCheck{
+			Expected:    boolP(true),
+			AlertActive: false,
+		},
+		RunningCheck: &StaticCheck{
+			Expected:    v.ExpectedRunning,
+			AlertActive: false,
+		},
+		AcceptedExitCodes: v.AcceptedExitCodes,
+		HealthCheck: &StaticCheck{
+			Expected:    v.ExpectedHealthy,
+			AlertActive: false,
+		},
+		InspectCheck:      &StaticCheck{},
+		StatsCheck:        &StaticCheck{},
+		MaxHealthStarting: v.MaxHealthStarting,
+		RestartCheck:      NewMetricCheck(v.MaxRestarts, CheckSettings{}),
+		RestartWindow:     v.RestartWindowDuration(),
+	}
+}
+
 // CheckContainers goes through and checks all the containers in a loop
 func CheckContainers(cnt []AlertdContainer, cli *client.Client, a *Alert) {
-	for _, c := range cnt {
+	for i := range cnt {
+		// use a pointer so that state which is not held in a check (like the previous
+		// stats sample) carries over to the next loop
+		c := &cnt[i]
+
 		// make sure we have a clean alert for this loop
 		c.Alert.Clear()
 
 		// handling whether the container exists, if these checks fail, the checking
 		// process should stop
-		j, err := ContainerInspect(&c, cli)
+		j, err := ContainerInspect(c, cli)
 		c.CheckStatics(j, err)
 
 		// if an alert should be sent that means it either failed existence or running
 		// checks which means that nothing more can be checked
 		if c.ChecksShouldStop() {
+			c.PreStats = nil  // the rates start over once the container is back
 			a.Concat(c.Alert) // add the alert in the container to the main alert
 			continue
 		}
 
-		s, err := GetStats(&c, cli)
+		s, err := GetStats(c, cli)
+		c.CheckMetrics(s, err)
+
+		if c.Alert.ShouldSend() {
+			a.Concat(c.Alert)
+		}
+	}
+}
+
+// CheckContainerMetrics only runs the metric checks on the containers, in events mode the
+// static checks are kept up to date by the docker events stream instead of polling.
+func CheckContainerMetrics(cnt []AlertdContainer, cli *client.Client, a *Alert) {
+	for i := range cnt {
+		c := &cnt[i]
+		c.Alert.Clear()
+
+		// the containers found since the last loop have not been seen by the events stream,
+		// the rest only need the checks that no event runs
+		switch {
+		case c.Unchecked:
+			j, err := ContainerInspect(c, cli)
+			c.CheckStatics(j, err)
+		default:
+			c.CheckTimeouts()
+		}
+
+		if c.ChecksShouldStop() {
+			c.PreStats = nil
+			a.Concat(c.Alert)
+			continue
+		}
+
+		s, err := GetStats(c, cli)
+		if c.IsUnknown(err) {
+			continue // the container was destroyed, the events stream takes care of it
+		}
 		c.CheckMetrics(s, err)
 
 		if c.Alert.ShouldSend() {
@@ -128,21 +211,98 @@ func Monitor(c *Conf, a *Alert) {
 		log.Fatal(err)
 	}
 
+	if c.Discovery {
+		list, err := ListContainers(cli)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.MergeLabels(list)
+	}
+
 	cnt := InitCheckers(c)
 
+	if c.StateFile != "" {
+		stateStore, err = LoadState(c.StateFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if c.HistoryFile != "" {
+		historyStore = &HistoryStore{Path: c.HistoryFile}
+	}
+	// the alerts of the last iterations are still being sent when a limited run ends
+	defer historyStore.Flush()
+
+	if len(c.Silences) > 0 || len(c.Maintenance) > 0 || c.SilenceFile != "" ||
+		c.SilenceToken != "" {
+
+		silences = NewSilenceStore(c)
+	}
+
+	// restore before the events stream starts checking the containers, so that the first
+	// events do not send the alerts that were active before the restart again
+	stateStore.Restore(cnt)
+
+	if c.Events {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go WatchEvents(ctx, &cnt, cli)
+		log.Println("watching docker events")
+	}
+
+	if c.Listen != "" {
+		health.Interval = time.Duration(c.Duration) * time.Millisecond
+		dashboard = NewDashboard()
+		snapshot = &Snapshot{}
+		go Serve(c.Listen)
+	}
+
+	// check runs a single iteration of the monitor, in events mode the first iteration
+	// still checks everything to find the current state of the containers.
+	check := func(i uint64) {
+		checkMu.Lock()
+		defer checkMu.Unlock()
+		defer ObservePoll(time.Now())
+
+		a.Clear()
+		if c.HasSelectors() || c.Discovery {
+			list, err := ListContainers(cli)
+			switch {
+			case err != nil:
+				log.Println(err)
+			default:
+				cnt = ResolveSelectors(c, cnt, list, a)
+				if c.Discovery {
+					cnt = DiscoverContainers(c, cnt, list, a)
+				}
+			}
+		}
+		stateStore.Restore(cnt)
+
+		switch {
+		case c.Events && i > 0:
+			CheckContainerMetrics(cnt, cli, a)
+		default:
+			CheckContainers(cnt, cli, a)
+		}
+		a.Evaluate()
+		stateStore.Save(cnt)
+		dashboard.Update(cnt)
+		snapshot.Update(cnt)
+		health.Poll(time.Now())
+	}
+
 	switch c.Iterations {
 	case 0:
-		for {
-			a.Clear()
-			CheckContainers(cnt, cli, a)
-			a.Evaluate()
+		for i := uint64(0); ; i++ {
+			check(i)
 			time.Sleep(time.Duration(c.Duration) * time.Millisecond)
 		}
 	default:
 		for i := uint64(0); i < c.Iterations; i++ {
-			a.Clear()
-			CheckContainers(cnt, cli, a)
-			a.Evaluate()
+			check(i)
 			time.Sleep(time.Duration(c.Duration) * time.Millisecond)
 		}
 	}
@@ -5,7 +5,9 @@ import (
 	"log"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -63,10 +65,32 @@ func init() {
 	RootCmd.PersistentFlags().Uint64P("duration", "t", 1000,
 		"the duration between monitor calls to the docker API in milliseconds (default 1000)")
 
+	RootCmd.PersistentFlags().Bool("discovery", false,
+		"read container settings from the alertd.* labels of the containers")
+	RootCmd.PersistentFlags().Bool("events", false,
+		"watch the docker events stream for container state changes, polling is only used "+
+			"for resource metrics")
+	RootCmd.PersistentFlags().String("state", "",
+		"file that keeps the alert state across restarts (default is no state file)")
+	RootCmd.PersistentFlags().String("history", "",
+		"file that every alert is recorded in (default is no history)")
+	RootCmd.PersistentFlags().String("silences", "",
+		"file that keeps the silences added with the silence command and the http api "+
+			"(default is no silence file)")
+	RootCmd.PersistentFlags().String("listen", "",
+		"address of the http server for the dashboard, /metrics, /api and /healthz, "+
+			"e.g. :9102 (default is no server)")
+
 	// Cobra also supports local flags, which will only run
 	// Bind all the flags to viper for handling
 	viper.BindPFlag("iterations", RootCmd.PersistentFlags().Lookup("iterations"))
 	viper.BindPFlag("duration", RootCmd.PersistentFlags().Lookup("duration"))
+	viper.BindPFlag("events", RootCmd.PersistentFlags().Lookup("events"))
+	viper.BindPFlag("discovery", RootCmd.PersistentFlags().Lookup("discovery"))
+	viper.BindPFlag("stateFile", RootCmd.PersistentFlags().Lookup("state"))
+	viper.BindPFlag("historyFile", RootCmd.PersistentFlags().Lookup("history"))
+	viper.BindPFlag("silenceFile", RootCmd.PersistentFlags().Lookup("silences"))
+	viper.BindPFlag("listen", RootCmd.PersistentFlags().Lookup("listen"))
 
 	// local flags for when this action is called directly.
 	//RootCmd.Flags().BoolVarP(&version, "version", "v", false, "Print `docker-alertd` version")
@@ -91,7 +115,8 @@ func initConfig() {
 	case err != nil:
 		log.Println(err)
 	default:
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// logged to stderr so that it does not end up in the output of history
+		log.Println("Using config file:", viper.ConfigFileUsed())
 	}
 
 	err = viper.Unmarshal(&Config)
@@ -103,11 +128,217 @@ func initConfig() {
 // Container gets data from the Unmarshaling of the configuration file JSON and stores
 // the data throughout the course of the monitor.
 type Container struct {
-	Name            string
-	MaxCPU          *uint64
-	MaxMem          *uint64
-	MinProcs        *uint64
-	ExpectedRunning *bool
+	Name              string
+	NameRegex         string
+	NameGlob          string
+	Label             string
+	Image             string
+	ComposeProject    string
+	ComposeService    string
+	MaxCPU            *uint64
+	MaxMem            *uint64
+	MemUnit           string
+	MaxMemPercent     *uint64
+	MinProcs          *uint64
+	MaxProcs          *uint64
+	MaxNetRx          *uint64
+	MaxNetTx          *uint64
+	MaxNetErrors      *uint64
+	MaxBlkioRead      *uint64
+	MaxBlkioWrite     *uint64
+	MaxBlkioReadOps   *uint64
+	MaxBlkioWriteOps  *uint64
+	ExpectedRunning   *bool
+	AcceptedExitCodes []int
+	ExpectedHealthy   *bool
+	MaxHealthStarting *uint64
+	MaxRestarts       *uint64
+	RestartWindow     *uint64
+	Alerters          []string
+	Checks            map[string]CheckSettings
+
+	// nameRegex is NameRegex compiled by the first match
+	nameRegex *regexp.Regexp
+}
+
+// CheckSettings are the settings of a single metric check, they are given in the config
+// under the name of the setting of the check (checks: {maxCpu: {samples: 5}})
+type CheckSettings struct {
+	// Samples is the number of consecutive samples the limit has to be breached before
+	// alerting, and healthy before recovering
+	Samples uint64
+
+	// Seconds is how long the limit has to be breached before alerting, and healthy
+	// before recovering
+	Seconds uint64
+
+	// Warning is the level that sends a warning alert, the limit of the check itself is
+	// the critical level
+	Warning *uint64
+
+	// Recover is the level an active alert recovers at, so that usage hovering around the
+	// limit does not recover and alert again on every sample
+	Recover *uint64
+
+	// FlapTransitions is how many times the check can change state within FlapSeconds
+	// (default 600) before it is flapping and its alerts are suppressed
+	FlapTransitions uint64
+	FlapSeconds     uint64
+}
+
+// DefaultFlapSeconds is the flap detection window in seconds when it is omitted
+const DefaultFlapSeconds = 600
+
+// FlapWindowDuration returns the flap detection window as a duration, or the default window
+func (s CheckSettings) FlapWindowDuration() time.Duration {
+	if s.FlapSeconds == 0 {
+		return DefaultFlapSeconds * time.Second
+	}
+	return time.Duration(s.FlapSeconds) * time.Second
+}
+
+// MetricCheckNames are the names of the container settings which are metric checks and can
+// be given check settings
+var MetricCheckNames = []string{"maxCpu", "maxMem", "maxMemPercent", "minProcs", "maxProcs",
+	"maxNetRx", "maxNetTx", "maxNetErrors", "maxBlkioRead", "maxBlkioWrite",
+	"maxBlkioReadOps", "maxBlkioWriteOps"}
+
+// Settings returns the check settings of the metric check, the config keys are lowercased
+// by viper so they are matched regardless of case
+func (c Container) Settings(name string) CheckSettings {
+	for k, v := range c.Checks {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return CheckSettings{}
+}
+
+// Limit returns the limit of the metric check with the name
+func (c Container) Limit(name string) *uint64 {
+	for _, v := range []struct {
+		Name  string
+		Limit *uint64
+	}{
+		{"maxCpu", c.MaxCPU},
+		{"maxMem", c.MaxMem},
+		{"maxMemPercent", c.MaxMemPercent},
+		{"minProcs", c.MinProcs},
+		{"maxProcs", c.MaxProcs},
+		{"maxNetRx", c.MaxNetRx},
+		{"maxNetTx", c.MaxNetTx},
+		{"maxNetErrors", c.MaxNetErrors},
+		{"maxBlkioRead", c.MaxBlkioRead},
+		{"maxBlkioWrite", c.MaxBlkioWrite},
+		{"maxBlkioReadOps", c.MaxBlkioReadOps},
+		{"maxBlkioWriteOps", c.MaxBlkioWriteOps},
+	} {
+		if strings.EqualFold(v.Name, name) {
+			return v.Limit
+		}
+	}
+	return nil
+}
+
+// beforeLimit returns true if the level is reached before the other level, which is under
+// it for the max checks and over it for minProcs
+func beforeLimit(name string, level, other *uint64) bool {
+	switch {
+	case level == nil || other == nil:
+		return true
+	case strings.EqualFold(name, "minProcs"):
+		return *level > *other
+	default:
+		return *level < *other
+	}
+}
+
+// ValidWarning returns false if the warning level of the check is not reached before its
+// limit, minProcs warns above its limit and the other checks warn below theirs
+func (c Container) ValidWarning(name string, warning *uint64) bool {
+	return beforeLimit(name, warning, c.Limit(name))
+}
+
+// ValidRecover returns false if the recover level of the check is not before its warning
+// level and its limit
+func (c Container) ValidRecover(name string, s CheckSettings) bool {
+	return beforeLimit(name, s.Recover, c.Limit(name)) && beforeLimit(name, s.Recover, s.Warning)
+}
+
+// DefaultRestartWindow is the restart window in seconds when it is omitted from the config
+const DefaultRestartWindow = 300
+
+// RestartWindowDuration returns the restart window as a duration, or the default window
+func (c Container) RestartWindowDuration() time.Duration {
+	if c.RestartWindow == nil {
+		return DefaultRestartWindow * time.Second
+	}
+	return time.Duration(*c.RestartWindow) * time.Second
+}
+
+// the units that maxMem can be given in
+const (
+	MemUnitMB  = "MB"
+	MemUnitMiB = "MiB"
+	MemUnitGiB = "GiB"
+)
+
+// MemUnits is the number of bytes in each of the memory units
+var MemUnits = map[string]uint64{
+	MemUnitMB:  1000000,
+	MemUnitMiB: 1 << 20,
+	MemUnitGiB: 1 << 30,
+}
+
+// Valid returns an error if the container settings are invalid
+func (c Container) Valid() error {
+	errString := []string{}
+
+	if err := c.ValidSelector(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if _, ok := MemUnits[c.MemUnit]; c.MemUnit != "" && !ok {
+		errString = append(errString, ErrMemUnit.Error())
+	}
+
+	if c.MaxMemPercent != nil && (*c.MaxMemPercent < 1 || *c.MaxMemPercent > 100) {
+		errString = append(errString, ErrMemPercent.Error())
+	}
+
+	for k, v := range c.Checks {
+		found := false
+		for _, name := range MetricCheckNames {
+			if strings.EqualFold(k, name) {
+				found = true
+			}
+		}
+
+		if !found {
+			errString = append(errString, errors.Wrap(ErrUnknownCheck, k).Error())
+		}
+
+		if !c.ValidWarning(k, v.Warning) {
+			errString = append(errString, errors.Wrap(ErrWarningLimit, k).Error())
+		}
+
+		if !c.ValidRecover(k, v) {
+			errString = append(errString, errors.Wrap(ErrRecoverLimit, k).Error())
+		}
+
+		if v.FlapTransitions == 1 {
+			errString = append(errString, errors.Wrap(ErrFlapTransitions, k).Error())
+		}
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, fmt.Sprintf("%s settings validation fail", c))
 }
 
 // Conf struct that combines containers and email settings structs
@@ -116,9 +347,44 @@ type Conf struct {
 	Email      Email
 	Slack      Slack
 	Pushover   Pushover
+	Webhook    Webhook
+	Webhooks   []Webhook
+	PagerDuty  PagerDuty
+	Opsgenie   Opsgenie
+	Teams      Teams
+	GoogleChat GoogleChat
+	Discord    Discord
+	Mattermost Mattermost
+	RocketChat RocketChat
 	Iterations uint64
 	Duration   uint64
+	Events     bool
 	Alerters   []Alerter
+
+	// StateFile is the file the alert state is kept in across restarts, HistoryFile is the
+	// file every alert is recorded in
+	StateFile   string
+	HistoryFile string
+
+	// Silences and Maintenance windows keep alerts from being sent, SilenceFile is the file
+	// the silences added with the silence command and the HTTP API are kept in.
+	// SilenceToken is the bearer token that adding and removing silences over HTTP needs,
+	// the HTTP API can only list the silences when it is empty.
+	Silences     []Silence
+	Maintenance  []Maintenance
+	SilenceFile  string
+	SilenceToken string
+
+	// Listen is the address of the HTTP server, it is not started when it is empty
+	Listen string
+
+	// Discovery reads the container settings from the labels of the containers, the
+	// labels start with DiscoveryPrefix (default "alertd")
+	Discovery       bool
+	DiscoveryPrefix string
+
+	// badLabels has the IDs of the containers with invalid label settings
+	badLabels map[string]bool
 }
 
 // ValidateEmailSettings calls valid on the Email settings and adds them to the alerters
@@ -167,6 +433,161 @@ func (c *Conf) ValidatePushoverSettings() error {
 	}
 }
 
+// ValidateWebhookSettings validates the webhook settings and adds them to the alerters, the
+// webhooks in the webhooks list are routed to by their ID
+func (c *Conf) ValidateWebhookSettings() error {
+	err := c.Webhook.Valid()
+	switch {
+	case reflect.DeepEqual(Webhook{}, c.Webhook):
+		// assume that the webhook was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Webhook)
+		log.Println("webhook alerts active")
+	}
+
+	ids := map[string]bool{}
+	for _, w := range c.Webhooks {
+		switch {
+		case w.ID == "":
+			return errors.Wrap(ErrWebhookID, "webhook settings validation fail")
+		case ids[w.ID]:
+			return errors.Wrap(ErrDuplicateWebhook, w.ID)
+		}
+		ids[w.ID] = true
+
+		if err := w.Valid(); err != nil {
+			return errors.Wrap(err, w.Name())
+		}
+		c.Alerters = append(c.Alerters, w)
+		log.Printf("%s alerts active", w.Name())
+	}
+	return nil
+}
+
+// ValidatePagerDutySettings validates pagerduty settings and adds it to the alerters
+func (c *Conf) ValidatePagerDutySettings() error {
+	err := c.PagerDuty.Valid()
+	switch {
+	case reflect.DeepEqual(PagerDuty{}, c.PagerDuty):
+		return nil // assume that pagerduty was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.PagerDuty)
+		log.Println("pagerduty alerts active")
+		return nil
+	}
+}
+
+// ValidateOpsgenieSettings validates opsgenie settings and adds it to the alerters
+func (c *Conf) ValidateOpsgenieSettings() error {
+	err := c.Opsgenie.Valid()
+	switch {
+	case reflect.DeepEqual(Opsgenie{}, c.Opsgenie):
+		return nil // assume that opsgenie was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Opsgenie)
+		log.Println("opsgenie alerts active")
+		return nil
+	}
+}
+
+// ValidateTeamsSettings validates teams settings and adds it to the alerters
+func (c *Conf) ValidateTeamsSettings() error {
+	err := c.Teams.Valid()
+	switch {
+	case reflect.DeepEqual(Teams{}, c.Teams):
+		return nil // assume that teams was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Teams)
+		log.Println("teams alerts active")
+		return nil
+	}
+}
+
+// ValidateGoogleChatSettings validates google chat settings and adds it to the alerters
+func (c *Conf) ValidateGoogleChatSettings() error {
+	err := c.GoogleChat.Valid()
+	switch {
+	case reflect.DeepEqual(GoogleChat{}, c.GoogleChat):
+		return nil // assume that google chat was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.GoogleChat)
+		log.Println("google chat alerts active")
+		return nil
+	}
+}
+
+// ValidateDiscordSettings validates discord settings and adds it to the alerters
+func (c *Conf) ValidateDiscordSettings() error {
+	err := c.Discord.Valid()
+	switch {
+	case reflect.DeepEqual(Discord{}, c.Discord):
+		return nil // assume that discord was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Discord)
+		log.Println("discord alerts active")
+		return nil
+	}
+}
+
+// ValidateMattermostSettings validates mattermost settings and adds it to the alerters
+func (c *Conf) ValidateMattermostSettings() error {
+	err := c.Mattermost.Valid()
+	switch {
+	case reflect.DeepEqual(Mattermost{}, c.Mattermost):
+		return nil // assume that mattermost was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.Mattermost)
+		log.Println("mattermost alerts active")
+		return nil
+	}
+}
+
+// ValidateRocketChatSettings validates rocket.chat settings and adds it to the alerters
+func (c *Conf) ValidateRocketChatSettings() error {
+	err := c.RocketChat.Valid()
+	switch {
+	case reflect.DeepEqual(RocketChat{}, c.RocketChat):
+		return nil // assume that rocket.chat was omitted and not wanted
+	case err != nil:
+		return err
+	default:
+		c.Alerters = append(c.Alerters, c.RocketChat)
+		log.Println("rocket.chat alerts active")
+		return nil
+	}
+}
+
+// ValidRoute returns an error if the alerter names are not active alerters
+func (c *Conf) ValidRoute(names []string) error {
+	for _, name := range names {
+		found := false
+		for _, a := range c.Alerters {
+			if a.Name() == name {
+				found = true
+			}
+		}
+
+		if !found {
+			return errors.Wrap(ErrUnknownAlerter, name)
+		}
+	}
+	return nil
+}
+
 // Validate validates the configuration that was passed in
 func (c *Conf) Validate() error {
 	// the error to wrap and return at the end
@@ -176,10 +597,16 @@ func (c *Conf) Validate() error {
 		errString = append(errString, ErrEmptyConfig.Error())
 	}
 
-	if len(c.Containers) < 1 {
+	if len(c.Containers) < 1 && !c.Discovery {
 		errString = append(errString, ErrNoContainers.Error())
 	}
 
+	for _, cnt := range c.Containers {
+		if err := cnt.Valid(); err != nil {
+			errString = append(errString, err.Error())
+		}
+	}
+
 	if err := c.ValidateEmailSettings(); err != nil {
 		errString = append(errString, err.Error())
 	}
@@ -192,6 +619,57 @@ func (c *Conf) Validate() error {
 		errString = append(errString, err.Error())
 	}
 
+	if err := c.ValidateWebhookSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidatePagerDutySettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidateOpsgenieSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidateTeamsSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidateGoogleChatSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidateDiscordSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidateMattermostSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if err := c.ValidateRocketChatSettings(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	for _, v := range c.Silences {
+		if err := v.Valid(); err != nil {
+			errString = append(errString, err.Error())
+		}
+	}
+
+	for _, m := range c.Maintenance {
+		if err := m.Valid(); err != nil {
+			errString = append(errString, err.Error())
+		}
+	}
+
+	// routes can only be checked once all of the alerters are known
+	for _, cnt := range c.Containers {
+		if err := c.ValidRoute(cnt.Alerters); err != nil {
+			errString = append(errString, errors.Wrap(err, cnt.String()).Error())
+		}
+	}
+
 	// if the length of the string of errors is 0 then everything has completed
 	// successfully and everything is valid.
 	if len(errString) == 0 {
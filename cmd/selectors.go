@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+)
+
+// the labels docker-compose puts on the containers it creates
+const (
+	ComposeProjectLabel = "com.docker.compose.project"
+	ComposeServiceLabel = "com.docker.compose.service"
+)
+
+// IsSelector returns true if the container is selected by something other than its exact
+// name, these containers are resolved against the container list on every loop.
+func (c Container) IsSelector() bool {
+	return c.NameRegex != "" || c.NameGlob != "" || c.Label != "" || c.Image != "" ||
+		c.ComposeProject != "" || c.ComposeService != ""
+}
+
+// ValidSelector returns an error if the selector settings of the container are invalid
+func (c Container) ValidSelector() error {
+	errString := []string{}
+
+	if c.Name != "" && c.IsSelector() {
+		errString = append(errString, ErrNameAndSelector.Error())
+	}
+
+	if _, err := regexp.Compile(c.NameRegex); err != nil {
+		errString = append(errString, errors.Wrap(ErrNameRegex, err.Error()).Error())
+	}
+
+	if _, err := path.Match(c.NameGlob, ""); err != nil {
+		errString = append(errString, errors.Wrap(ErrNameGlob, err.Error()).Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(errString, ", "))
+}
+
+// String describes the selector for the logs
+func (c Container) String() string {
+	s := []string{}
+	for _, v := range []struct{ Key, Value string }{
+		{"name", c.Name},
+		{"nameRegex", c.NameRegex},
+		{"nameGlob", c.NameGlob},
+		{"label", c.Label},
+		{"image", c.Image},
+		{"composeProject", c.ComposeProject},
+		{"composeService", c.ComposeService},
+	} {
+		if v.Value != "" {
+			s = append(s, fmt.Sprintf("%s=%s", v.Key, v.Value))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// ContainerName returns the name of the container from the container list without the
+// leading slash
+func ContainerName(l types.Container) string {
+	if len(l.Names) == 0 {
+		return l.ID
+	}
+	return strings.TrimPrefix(l.Names[0], "/")
+}
+
+// LabelMatches returns true if the labels have the key=value label, or just the key when
+// the label has no value
+func LabelMatches(label string, labels map[string]string) bool {
+	kv := strings.SplitN(label, "=", 2)
+	v, ok := labels[kv[0]]
+	if len(kv) == 1 {
+		return ok
+	}
+	return ok && v == kv[1]
+}
+
+// ImageMatches returns true if the image of the container is the image reference, an image
+// reference without a tag matches every tag of the image.
+func ImageMatches(ref, image string) bool {
+	switch {
+	case ref == image:
+		return true
+	case strings.Contains(path.Base(ref), ":") || strings.Contains(ref, "@"):
+		return false // the reference has a tag or digest so it has to be an exact match
+	default:
+		return strings.HasPrefix(image, ref+":") || strings.HasPrefix(image, ref+"@")
+	}
+}
+
+// compiledRegex returns the compiled name regex of the selector, it is compiled on the first
+// call and kept for the next ones
+func (c *Container) compiledRegex() (*regexp.Regexp, error) {
+	if c.nameRegex == nil || c.nameRegex.String() != c.NameRegex {
+		re, err := regexp.Compile(c.NameRegex)
+		if err != nil {
+			return nil, err
+		}
+		c.nameRegex = re
+	}
+	return c.nameRegex, nil
+}
+
+// Matches returns true if the container from the container list matches every selector
+// that is set
+func (c *Container) Matches(l types.Container) bool {
+	name := ContainerName(l)
+
+	if c.NameRegex != "" {
+		re, err := c.compiledRegex()
+		if err != nil || !re.MatchString(name) {
+			return false
+		}
+	}
+
+	if c.NameGlob != "" {
+		ok, err := path.Match(c.NameGlob, name)
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	switch {
+	case c.Label != "" && !LabelMatches(c.Label, l.Labels):
+		return false
+	case c.Image != "" && !ImageMatches(c.Image, l.Image):
+		return false
+	case c.ComposeProject != "" && l.Labels[ComposeProjectLabel] != c.ComposeProject:
+		return false
+	case c.ComposeService != "" && l.Labels[ComposeServiceLabel] != c.ComposeService:
+		return false
+	default:
+		return true
+	}
+}
+
+// HasSelectors returns true if any of the containers in the config use selectors
+func (c *Conf) HasSelectors() bool {
+	for _, v := range c.Containers {
+		if v.IsSelector() {
+			return true
+		}
+	}
+	return false
+}
+
+// findChecker returns the checker that was created by the selector for the container ID
+func findChecker(cnt []AlertdContainer, sel *Container, id string) (AlertdContainer, bool) {
+	for _, a := range cnt {
+		if a.Selector == sel && a.ID == id {
+			return a, true
+		}
+	}
+	return AlertdContainer{}, false
+}
+
+// ResolveSelectors matches the selectors in the config against the container list. The
+// checkers of containers that still match are kept (along with their alert state), new
+// checkers are created for containers that started matching, and the checkers of containers
+// that are gone are retired, their active alerts are recovered in a. Containers given by
+// name are kept as they are.
+func ResolveSelectors(c *Conf, cnt []AlertdContainer, list []types.Container,
+	a *Alert) []AlertdContainer {
+
+	resolved := []AlertdContainer{}
+	for _, a := range cnt {
+		if a.Selector == nil {
+			resolved = append(resolved, a)
+		}
+	}
+
+	for i := range c.Containers {
+		sel := &c.Containers[i]
+		if !sel.IsSelector() {
+			continue
+		}
+
+		for _, l := range list {
+			if !sel.Matches(l) {
+				continue
+			}
+
+			if r, ok := findChecker(cnt, sel, l.ID); ok {
+				resolved = append(resolved, r)
+				continue
+			}
+
+			n := NewAlertdContainer(*sel, ContainerName(l))
+			n.ID = l.ID
+			n.Selector = sel
+			n.Unchecked = true
+			resolved = append(resolved, n)
+			log.Printf("monitoring %s (selector: %s)", n.Name, sel)
+		}
+	}
+
+	for _, r := range cnt {
+		if _, ok := findChecker(resolved, r.Selector, r.ID); r.Selector != nil && !ok {
+			log.Printf("stopped monitoring %s, it no longer matches (selector: %s)", r.Name,
+				r.Selector)
+			r.Alert.Clear()
+			r.Retire()
+			a.Concat(r.Alert)
+		}
+	}
+
+	return resolved
+}
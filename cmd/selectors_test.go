@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	l := types.Container{
+		ID:    "0123456789ab",
+		Names: []string{"/myapp_worker_1"},
+		Image: "registry:5000/myapp/worker:1.2",
+		Labels: map[string]string{
+			ComposeProjectLabel: "myapp",
+			ComposeServiceLabel: "worker",
+			"team":              "backend",
+		},
+	}
+
+	tests := []struct {
+		Name     string
+		Selector Container
+		Expected bool
+	}{
+		{Name: "name regex", Selector: Container{NameRegex: "^myapp_worker_\\d+$"}, Expected: true},
+		{Name: "name regex miss", Selector: Container{NameRegex: "^web"}, Expected: false},
+		{Name: "name glob", Selector: Container{NameGlob: "myapp_*"}, Expected: true},
+		{Name: "label key", Selector: Container{Label: "team"}, Expected: true},
+		{Name: "label key=value", Selector: Container{Label: "team=backend"}, Expected: true},
+		{Name: "label wrong value", Selector: Container{Label: "team=ops"}, Expected: false},
+		{Name: "image without tag", Selector: Container{Image: "registry:5000/myapp/worker"}, Expected: true},
+		{Name: "image with tag", Selector: Container{Image: "registry:5000/myapp/worker:1.2"}, Expected: true},
+		{Name: "image wrong tag", Selector: Container{Image: "registry:5000/myapp/worker:1.3"}, Expected: false},
+		{Name: "compose service", Selector: Container{ComposeProject: "myapp", ComposeService: "worker"}, Expected: true},
+		{Name: "all selectors must match", Selector: Container{ComposeProject: "myapp", NameGlob: "web*"}, Expected: false},
+	}
+
+	for _, test := range tests {
+		if got := test.Selector.Matches(l); got != test.Expected {
+			t.Errorf("%s: expected match: %t, got: %t", test.Name, test.Expected, got)
+		}
+	}
+}
+
+func TestResolveSelectors(t *testing.T) {
+	c := &Conf{
+		Containers: []Container{
+			{Name: "static"},
+			{NameGlob: "worker_*", MaxCPU: uint64P(50)},
+		},
+	}
+
+	cnt := InitCheckers(c)
+	if len(cnt) != 1 {
+		t.Fatalf("expected 1 checker for the named container, got %d", len(cnt))
+	}
+
+	list := []types.Container{
+		{ID: "1", Names: []string{"/worker_1"}},
+		{ID: "2", Names: []string{"/worker_2"}},
+		{ID: "3", Names: []string{"/web_1"}},
+	}
+
+	a := &Alert{Messages: []error{}}
+	cnt = ResolveSelectors(c, cnt, list, a)
+	switch {
+	case len(cnt) != 3:
+		t.Fatalf("expected 3 checkers after resolving, got %d", len(cnt))
+	case !cnt[1].Unchecked || cnt[0].Unchecked:
+		t.Errorf("expected only the new checkers to need their static checks")
+	}
+
+	// the state of a checker has to survive resolving again
+	cnt[1].CPUCheck.AlertActive = true
+	cnt[2].CPUCheck.AlertActive = true
+
+	cnt = ResolveSelectors(c, cnt, list[1:], a)
+	switch {
+	case len(cnt) != 2:
+		t.Fatalf("expected worker_1 to be retired, got %d checkers", len(cnt))
+	case a.Len() != 1 || !ErrContainsErr(a.Messages[0], ErrNoLongerMonitored):
+		t.Errorf("expected the active alert of worker_1 to be recovered, got: %s", a.Dump())
+	case a.SeverityOf(0) != SeverityOK || a.TransitionOf(0).Check != "maxCpu":
+		t.Errorf("expected a recovery of maxCpu, got: %s %+v", a.SeverityOf(0),
+			a.TransitionOf(0))
+	}
+
+	cnt = ResolveSelectors(c, cnt, list, a)
+	for _, a := range cnt {
+		if a.Name == "worker_2" && !a.CPUCheck.AlertActive {
+			t.Errorf("expected alert state of worker_2 to be kept")
+		}
+		if a.Name == "worker_1" && a.CPUCheck.AlertActive {
+			t.Errorf("expected worker_1 to get a new checker")
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// snapshot has the state of the containers that the HTTP server serves, it is nil when the
+// server is not running
+var snapshot *Snapshot
+
+// Snapshot is the state of the containers from the end of the last monitor iteration or
+// event. It is published like the dashboard is, so that the handlers do not wait on checkMu
+// while a poll is waiting on the docker API.
+type Snapshot struct {
+	mu       sync.Mutex
+	metrics  []byte
+	statuses []ContainerStatus
+}
+
+// Update publishes the state of the containers, it is called with checkMu held
+func (s *Snapshot) Update(cnt []AlertdContainer) {
+	if s == nil {
+		return
+	}
+
+	b := &bytes.Buffer{}
+	WriteContainerMetrics(b, cnt)
+	statuses := Statuses(cnt)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = b.Bytes()
+	s.statuses = statuses
+}
+
+// Metrics returns the published metrics of the containers in the prometheus text format
+func (s *Snapshot) Metrics() []byte {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metrics
+}
+
+// Statuses returns the published state of the containers and their checks
+func (s *Snapshot) Statuses() []ContainerStatus {
+	if s == nil {
+		return []ContainerStatus{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.statuses == nil {
+		return []ContainerStatus{} // nothing has been published yet
+	}
+	return s.statuses
+}
+
+// NewServeMux returns the handlers of the HTTP server, the state of the containers is read
+// from the published snapshot so the handlers never see a check half way through
+func NewServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		b := bytes.NewBuffer(snapshot.Metrics())
+		WriteAlertdMetrics(b)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		b.WriteTo(w)
+	})
+
+	mux.HandleFunc("/api/containers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, snapshot.Statuses())
+	})
+
+	mux.HandleFunc("/api/containers/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/api/containers/")
+		for _, c := range snapshot.Statuses() {
+			if c.Name == name || c.ID != "" && c.ID == name {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown container"})
+	})
+
+	mux.HandleFunc("/api/silences", func(w http.ResponseWriter, r *http.Request) {
+		if silences == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			l, err := silences.List()
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, l)
+		case http.MethodPost:
+			if !authorizeSilenceWrite(w, r) {
+				return
+			}
+
+			now := time.Now()
+			v := Silence{}
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			if v.End == "" {
+				v.End = now.Add(time.Hour).Format(time.RFC3339)
+			}
+
+			v, err := silences.Add(v, now)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusCreated, v)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		}
+	})
+
+	mux.HandleFunc("/api/silences/", func(w http.ResponseWriter, r *http.Request) {
+		if silences == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "DELETE")
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if !authorizeSilenceWrite(w, r) {
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/silences/")
+		if err := silences.Remove(id, time.Now()); err != nil {
+			code := http.StatusInternalServerError
+			if ErrContainsErr(err, ErrUnknownSilence) {
+				code = http.StatusNotFound
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		s := health.Status(time.Now())
+		code := http.StatusOK
+		if !s.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, s)
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, dashboardHTML)
+	})
+
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		if dashboard == nil {
+			http.NotFound(w, r)
+			return
+		}
+		dashboard.ServeEvents(w, r)
+	})
+
+	return mux
+}
+
+// authorizeSilenceWrite returns true if the request may add or remove silences, which needs
+// the silenceToken from the config as a bearer token. Otherwise it writes the error response.
+func authorizeSilenceWrite(w http.ResponseWriter, r *http.Request) bool {
+	token := Config.SilenceToken
+	if token == "" {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": ErrSilenceReadOnly.Error()})
+		return false
+	}
+
+	auth := []byte(r.Header.Get("Authorization"))
+	if subtle.ConstantTimeCompare(auth, []byte("Bearer "+token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": ErrSilenceToken.Error()})
+		return false
+	}
+	return true
+}
+
+// writeJSON writes the value as the JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(errors.Wrap(err, "http response"))
+	}
+}
+
+// Serve runs the HTTP server on the address
+func Serve(addr string) {
+	log.Printf("serving http on %s", addr)
+	err := http.ListenAndServe(addr, NewServeMux())
+	log.Fatal(errors.Wrap(err, "http server"))
+}
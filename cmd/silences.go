@@ -0,0 +1,766 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// silences decides which alert messages are silenced, it is nil when nothing is silenced
+var silences *SilenceStore
+
+// Silence keeps the alerts of the containers that match the Container glob and the
+// selectors (all of them when none are set) and of the Check (all checks when it is empty)
+// from being sent between Start and End. The selectors are the ones of the containers in the
+// config. The times are RFC3339, an empty Start is a silence that started already.
+type Silence struct {
+	ID             string `json:"id"`
+	Container      string `json:"container,omitempty"`
+	NameRegex      string `json:"nameRegex,omitempty"`
+	Label          string `json:"label,omitempty"`
+	Image          string `json:"image,omitempty"`
+	ComposeProject string `json:"composeProject,omitempty"`
+	ComposeService string `json:"composeService,omitempty"`
+	Check          string `json:"check,omitempty"`
+	Start          string `json:"start,omitempty"`
+	End            string `json:"end"`
+	Comment        string `json:"comment,omitempty"`
+
+	selector *Container
+}
+
+// Selector returns the containers that the silence covers
+func (s *Silence) Selector() *Container {
+	if s.selector == nil {
+		s.selector = &Container{
+			NameGlob:       s.Container,
+			NameRegex:      s.NameRegex,
+			Label:          s.Label,
+			Image:          s.Image,
+			ComposeProject: s.ComposeProject,
+			ComposeService: s.ComposeService,
+		}
+	}
+	return s.selector
+}
+
+// Times returns the start and the end of the silence
+func (s Silence) Times() (start, end time.Time, err error) {
+	if s.Start != "" {
+		start, err = time.Parse(time.RFC3339, s.Start)
+		if err != nil {
+			return start, end, errors.Wrap(ErrSilenceTime, err.Error())
+		}
+	}
+
+	end, err = time.Parse(time.RFC3339, s.End)
+	if err != nil {
+		return start, end, errors.Wrap(ErrSilenceTime, err.Error())
+	}
+	return start, end, nil
+}
+
+// Valid returns an error if the silence settings are invalid
+func (s Silence) Valid() error {
+	errString := []string{}
+
+	if start, end, err := s.Times(); err != nil {
+		errString = append(errString, err.Error())
+	} else if !end.After(start) {
+		errString = append(errString, ErrSilenceEnd.Error())
+	}
+
+	if err := s.Selector().ValidSelector(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "silence validation fail")
+}
+
+// Expired returns true if the silence has ended
+func (s Silence) Expired(now time.Time) bool {
+	_, end, err := s.Times()
+	return err == nil && !now.Before(end)
+}
+
+// Matches returns true if the silence is active and covers the alert message
+func (s *Silence) Matches(t Transition, now time.Time) bool {
+	start, end, err := s.Times()
+	if err != nil || now.Before(start) || !now.Before(end) {
+		return false
+	}
+	return matchesTransition(s.Selector(), s.Check, t)
+}
+
+// Maintenance is a recurring silence, it starts at every time of the cron Schedule
+// ("minute hour day-of-month month day-of-week") and lasts for Duration seconds. The
+// containers and checks are selected like they are for a silence.
+type Maintenance struct {
+	Container      string
+	NameRegex      string
+	Label          string
+	Image          string
+	ComposeProject string
+	ComposeService string
+	Check          string
+	Schedule       string
+	Duration       uint64
+
+	selector *Container
+	schedule *Schedule
+}
+
+// ParsedSchedule returns the parsed Schedule, it is only parsed the first time
+func (m *Maintenance) ParsedSchedule() (*Schedule, error) {
+	if m.schedule == nil {
+		sched, err := ParseSchedule(m.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		m.schedule = &sched
+	}
+	return m.schedule, nil
+}
+
+// Selector returns the containers that the maintenance window covers
+func (m *Maintenance) Selector() *Container {
+	if m.selector == nil {
+		m.selector = &Container{
+			NameGlob:       m.Container,
+			NameRegex:      m.NameRegex,
+			Label:          m.Label,
+			Image:          m.Image,
+			ComposeProject: m.ComposeProject,
+			ComposeService: m.ComposeService,
+		}
+	}
+	return m.selector
+}
+
+// Valid returns an error if the maintenance window settings are invalid
+func (m Maintenance) Valid() error {
+	errString := []string{}
+
+	if _, err := ParseSchedule(m.Schedule); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if m.Duration == 0 {
+		errString = append(errString, ErrMaintenanceDuration.Error())
+	}
+
+	if err := m.Selector().ValidSelector(); err != nil {
+		errString = append(errString, err.Error())
+	}
+
+	if len(errString) == 0 {
+		return nil
+	}
+
+	delimErr := strings.Join(errString, ", ")
+	err := errors.New(delimErr)
+
+	return errors.Wrap(err, "maintenance validation fail")
+}
+
+// Active returns true if a maintenance window started within Duration before now
+func (m *Maintenance) Active(now time.Time) bool {
+	sched, err := m.ParsedSchedule()
+	if err != nil {
+		return false
+	}
+
+	// the first start after the window that ends now started
+	d := time.Duration(m.Duration) * time.Second
+	start, ok := sched.Next(now.Add(-d).Add(time.Nanosecond))
+	return ok && !start.After(now)
+}
+
+// Matches returns true if the maintenance window is active and covers the alert message
+func (m *Maintenance) Matches(t Transition, now time.Time) bool {
+	return m.Active(now) && matchesTransition(m.Selector(), m.Check, t)
+}
+
+// matchesTransition returns true if the container of the message matches the selector and
+// the check is the check of the message, empty settings match everything
+func matchesTransition(sel *Container, check string, t Transition) bool {
+	l := types.Container{
+		ID:     t.ID,
+		Names:  []string{"/" + t.Container},
+		Image:  t.Image,
+		Labels: t.Labels,
+	}
+	return sel.Matches(l) && (check == "" || strings.EqualFold(check, t.Check))
+}
+
+// Schedule is a parsed cron schedule, each field has the values it matches
+type Schedule struct {
+	fields [5]map[int]bool
+
+	// anyDay is set when one of the day fields is *, cron matches either of the day
+	// fields when both are restricted and both of them otherwise
+	anyDay bool
+}
+
+// the ranges of the cron fields, minute, hour, day of month, month and day of week
+var cronRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// ParseSchedule parses a cron schedule with the fields "minute hour day-of-month month
+// day-of-week", each field can be *, a value, a range (1-5), a list (1,3) and have a step
+// (*/15)
+func ParseSchedule(s string) (Schedule, error) {
+	sched := Schedule{}
+
+	f := strings.Fields(s)
+	if len(f) != 5 {
+		return sched, errors.Wrap(ErrSchedule, s)
+	}
+
+	for i, field := range f {
+		values, err := parseCronField(field, cronRanges[i][0], cronRanges[i][1])
+		if err != nil {
+			return sched, errors.Wrap(ErrSchedule, s)
+		}
+		sched.fields[i] = values
+	}
+
+	// sunday is 0 or 7
+	if sched.fields[4][7] {
+		sched.fields[4][0] = true
+	}
+
+	sched.anyDay = f[2] == "*" || f[4] == "*"
+	return sched, nil
+}
+
+// parseCronField returns the values of a cron field within min and max
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, ErrSchedule
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(r[0])
+			hi, err2 = strconv.Atoi(r[1])
+			if err1 != nil || err2 != nil {
+				return nil, ErrSchedule
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, ErrSchedule
+			}
+			lo, hi = v, v
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, ErrSchedule
+		}
+
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// Matches returns true if the schedule fires at the minute of the time
+func (s Schedule) Matches(t time.Time) bool {
+	if !s.fields[0][t.Minute()] || !s.fields[1][t.Hour()] || !s.fields[3][int(t.Month())] {
+		return false
+	}
+
+	return s.matchesDay(t)
+}
+
+// matchesDay returns true if the day fields match the day of t
+func (s Schedule) matchesDay(t time.Time) bool {
+	dom, dow := s.fields[2][t.Day()], s.fields[4][int(t.Weekday())]
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time the schedule matches from t on, t is rounded up to the
+// minute. The fields which do not match are skipped as a whole, so it takes at most a few
+// thousand steps. ok is false when it does not match within five years (February 30th).
+func (s Schedule) Next(t time.Time) (next time.Time, ok bool) {
+	if r := t.Truncate(time.Minute); r.Before(t) {
+		t = r.Add(time.Minute)
+	}
+
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case !s.fields[3][int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.fields[1][t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.fields[0][t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// SilenceStore has the silences from the config, the maintenance windows and the silences
+// that are added with the silence command or the HTTP API. Those are kept in the silence
+// file (when there is one) which is read again when it changes, so that the silences added
+// by the command are picked up by the running daemon.
+//
+// The last silenced transition of each check is held back, and sent once the silences that
+// cover it have ended unless the check is back in the state it was last sent with.
+type SilenceStore struct {
+	Path        string
+	Config      []Silence
+	Maintenance []Maintenance
+
+	mu       sync.Mutex
+	silences []Silence
+	modTime  time.Time
+	held     map[string]*Alert
+	sent     map[string]Severity
+}
+
+// NewSilenceStore returns the silence store of the config, the schedules of the maintenance
+// windows are parsed once here instead of for every alert message
+func NewSilenceStore(c *Conf) *SilenceStore {
+	maintenance := append([]Maintenance{}, c.Maintenance...)
+	for i := range maintenance {
+		if _, err := maintenance[i].ParsedSchedule(); err != nil {
+			log.Println(err) // the config was validated, it does not happen
+		}
+	}
+
+	return &SilenceStore{
+		Path:        c.SilenceFile,
+		Config:      c.Silences,
+		Maintenance: maintenance,
+		silences:    []Silence{},
+	}
+}
+
+// reload reads the silence file when it changed since it was last read
+func (s *SilenceStore) reload() error {
+	if s.Path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(s.Path)
+	switch {
+	case os.IsNotExist(err):
+		s.silences = []Silence{}
+		return nil
+	case err != nil:
+		return errors.Wrap(err, "reading silence file")
+	case info.ModTime().Equal(s.modTime):
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return errors.Wrap(err, "reading silence file")
+	}
+
+	silences := []Silence{}
+	if err := json.Unmarshal(b, &silences); err != nil {
+		return errors.Wrap(err, "parsing silence file")
+	}
+
+	s.silences = silences
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// write writes the silences that have not expired to the silence file
+func (s *SilenceStore) write(now time.Time) error {
+	active := []Silence{}
+	for _, v := range s.silences {
+		if !v.Expired(now) {
+			active = append(active, v)
+		}
+	}
+	s.silences = active
+
+	if s.Path == "" {
+		return nil // the silences are only kept in memory
+	}
+
+	b, err := json.MarshalIndent(s.silences, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding silences")
+	}
+
+	tmp := s.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "writing silence file")
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return errors.Wrap(err, "writing silence file")
+	}
+
+	if info, err := os.Stat(s.Path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// List returns all of the silences, the ones from the config first
+func (s *SilenceStore) List() ([]Silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.reload()
+	return append(append([]Silence{}, s.Config...), s.silences...), err
+}
+
+// Add validates the silence, gives it an ID and stores it
+func (s *SilenceStore) Add(v Silence, now time.Time) (Silence, error) {
+	if err := v.Valid(); err != nil {
+		return v, err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return v, errors.Wrap(err, "silence id")
+	}
+	v.ID = hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return v, err
+	}
+	s.silences = append(s.silences, v)
+	return v, s.write(now)
+}
+
+// Remove removes the silence with the ID, the silences from the config can not be removed
+func (s *SilenceStore) Remove(id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(); err != nil {
+		return err
+	}
+
+	for i, v := range s.silences {
+		if v.ID == id {
+			s.silences = append(s.silences[:i], s.silences[i+1:]...)
+			return s.write(now)
+		}
+	}
+	return errors.Wrap(ErrUnknownSilence, id)
+}
+
+// Silenced returns true if a silence or a maintenance window covers the alert message
+func (s *SilenceStore) Silenced(t Transition, now time.Time) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.silenced(t, now)
+}
+
+// checkKey returns the key of the check of the transition in the held and sent transitions
+func checkKey(t Transition) string {
+	return t.Container + "/" + t.Check
+}
+
+// Hold keeps the last silenced transition of each check, messages that are not the
+// transition of a check are not held
+func (s *SilenceStore) Hold(a *Alert) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held == nil {
+		s.held = map[string]*Alert{}
+	}
+
+	for i := range a.Messages {
+		if t := a.TransitionOf(i); t.Check != "" {
+			s.held[checkKey(t)] = a.Filter(func(j int) bool { return j == i })
+		}
+	}
+}
+
+// Restored records the checks of the container that were restored from the state file as
+// active as sent, the alerts were sent before the restart and their recoveries have to be
+// released when they are silenced
+func (s *SilenceStore) Restored(c *AlertdContainer) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sent == nil {
+		s.sent = map[string]Severity{}
+	}
+
+	restored := map[string]Severity{}
+	for k, m := range c.MetricChecks() {
+		if m != nil && m.AlertActive {
+			restored[k] = m.Severity()
+		}
+	}
+	for k, st := range c.StaticChecks() {
+		if st != nil && st.AlertActive {
+			restored[k] = SeverityCritical
+		}
+	}
+
+	for k, sev := range restored {
+		key := checkKey(c.Transition(k, nil, nil))
+		if _, ok := s.sent[key]; !ok {
+			s.sent[key] = sev
+		}
+	}
+}
+
+// Release records the severities that the checks are sent with in a, and returns the held
+// transitions whose silences have ended. A held transition is dropped when its check is
+// back in the state that was last sent, so a check that failed and recovered within a
+// silence does not alert at all while a silenced recovery of a sent alert is still sent.
+func (s *SilenceStore) Release(a *Alert, now time.Time) *Alert {
+	released := &Alert{Messages: []error{}}
+	if s == nil {
+		return released
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sent == nil {
+		s.sent = map[string]Severity{}
+	}
+
+	for i := range a.Messages {
+		if t := a.TransitionOf(i); t.Check != "" {
+			delete(s.held, checkKey(t))
+			s.sent[checkKey(t)] = a.SeverityOf(i)
+		}
+	}
+
+	keys := []string{}
+	for k := range s.held {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		h := s.held[k]
+		if s.silenced(h.TransitionOf(0), now) {
+			continue
+		}
+
+		delete(s.held, k)
+		if h.SeverityOf(0) != s.sent[k] {
+			released.Concat(h)
+			s.sent[k] = h.SeverityOf(0)
+		}
+	}
+	return released
+}
+
+// silenced is Silenced for a caller that holds the lock
+func (s *SilenceStore) silenced(t Transition, now time.Time) bool {
+	if err := s.reload(); err != nil {
+		log.Println(err)
+	}
+
+	// the silences are matched in place so that their selectors are only compiled once
+	for i := range s.Config {
+		if s.Config[i].Matches(t, now) {
+			return true
+		}
+	}
+
+	for i := range s.silences {
+		if s.silences[i].Matches(t, now) {
+			return true
+		}
+	}
+
+	for i := range s.Maintenance {
+		if s.Maintenance[i].Matches(t, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// silenceCmd manages the silences in the silence file
+var silenceCmd = &cobra.Command{
+	Use:   "silence",
+	Short: "add, list and remove silences",
+	Long: `Silences keep the alerts of containers (by name or glob) and checks from being sent
+for a while, during a deploy for example. The alerts are still logged and recorded in the
+history. The silences are kept in the silence file which the daemon picks up.`,
+}
+
+var silenceAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "add a silence",
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v := Silence{
+			Container:      silenceContainer,
+			NameRegex:      silenceNameRegex,
+			Label:          silenceLabel,
+			Image:          silenceImage,
+			ComposeProject: silenceComposeProject,
+			ComposeService: silenceComposeService,
+			Check:          silenceCheck,
+			Start:          silenceStart,
+			End:            silenceEnd,
+			Comment:        silenceComment,
+		}
+		if v.End == "" {
+			v.End = now.Add(silenceDuration).Format(time.RFC3339)
+		}
+
+		v, err := silenceFileStore().Add(v, now)
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("added silence %s until %s\n", v.ID, v.End)
+	},
+}
+
+var silenceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the silences",
+	Run: func(cmd *cobra.Command, args []string) {
+		l, err := silenceFileStore().List()
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		for _, v := range l {
+			if v.Expired(now) {
+				continue
+			}
+			id := v.ID
+			if id == "" {
+				id = "config"
+			}
+			fmt.Printf("%s\tcontainers: %q\tcheck: %q\tstart: %s\tend: %s\t%s\n", id,
+				v.Selector(), v.Check, v.Start, v.End, v.Comment)
+		}
+	},
+}
+
+var silenceRemoveCmd = &cobra.Command{
+	Use:   "remove [id]",
+	Short: "remove a silence",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := silenceFileStore().Remove(args[0], time.Now()); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("removed silence %s\n", args[0])
+	},
+}
+
+// silenceFileStore returns the silence store of the config for the silence commands, which
+// only work with a silence file
+func silenceFileStore() *SilenceStore {
+	if Config.SilenceFile == "" {
+		log.Println("no silence file, set silenceFile in the config or --silences")
+		os.Exit(1)
+	}
+	return NewSilenceStore(&Config)
+}
+
+var (
+	silenceContainer      string
+	silenceNameRegex      string
+	silenceLabel          string
+	silenceImage          string
+	silenceComposeProject string
+	silenceComposeService string
+	silenceCheck          string
+	silenceStart          string
+	silenceEnd            string
+	silenceDuration       time.Duration
+	silenceComment        string
+)
+
+func init() {
+	RootCmd.AddCommand(silenceCmd)
+	silenceCmd.AddCommand(silenceAddCmd, silenceListCmd, silenceRemoveCmd)
+
+	silenceAddCmd.Flags().StringVar(&silenceContainer, "container", "",
+		"container name or glob (default is all containers)")
+	silenceAddCmd.Flags().StringVar(&silenceNameRegex, "name-regex", "",
+		"regular expression the container name has to match")
+	silenceAddCmd.Flags().StringVar(&silenceLabel, "label", "",
+		"label the container has to have, key=value or just key")
+	silenceAddCmd.Flags().StringVar(&silenceImage, "image", "",
+		"image of the container, an image without a tag matches every tag")
+	silenceAddCmd.Flags().StringVar(&silenceComposeProject, "compose-project", "",
+		"docker-compose project of the container")
+	silenceAddCmd.Flags().StringVar(&silenceComposeService, "compose-service", "",
+		"docker-compose service of the container")
+	silenceAddCmd.Flags().StringVar(&silenceCheck, "check", "",
+		"check name, e.g. running or maxCpu (default is all checks)")
+	silenceAddCmd.Flags().StringVar(&silenceStart, "start", "",
+		"RFC3339 start time (default is now)")
+	silenceAddCmd.Flags().StringVar(&silenceEnd, "end", "", "RFC3339 end time")
+	silenceAddCmd.Flags().DurationVar(&silenceDuration, "duration", time.Hour,
+		"how long the silence lasts when there is no end time")
+	silenceAddCmd.Flags().StringVar(&silenceComment, "comment", "", "why it is silenced")
+}
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleMatches(t *testing.T) {
+	// a sunday
+	sun := time.Date(2026, time.October, 18, 2, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		Schedule string
+		Time     time.Time
+		Matches  bool
+	}{
+		{"* * * * *", sun, true},
+		{"30 2 * * *", sun, true},
+		{"30 2 * * 0", sun, true},
+		{"30 2 * * 7", sun, true},
+		{"30 2 * * 1-5", sun, false},
+		{"*/15 2 * * *", sun, true},
+		{"*/20 2 * * *", sun, false},
+		{"0,30 1-3 * 10 *", sun, true},
+		{"30 2 1 * 0", sun, true},  // either day field matches when both are restricted
+		{"30 2 1 * 1", sun, false}, // neither of them matches
+		{"30 2 1 * *", sun, false}, // only the day of month is restricted
+	}
+
+	for _, c := range cases {
+		s, err := ParseSchedule(c.Schedule)
+		switch {
+		case err != nil:
+			t.Errorf("%s: %s", c.Schedule, err)
+		case s.Matches(c.Time) != c.Matches:
+			t.Errorf("%s: expected match %t at %s", c.Schedule, c.Matches, c.Time)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *",
+		"*/0 * * * *", "a * * * *"} {
+		if _, err := ParseSchedule(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	sun := time.Date(2026, time.October, 18, 2, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		Schedule string
+		From     time.Time
+		Next     time.Time
+	}{
+		{"30 2 * * 0", sun, sun},
+		{"30 2 * * 0", sun.Add(time.Second), sun.AddDate(0, 0, 7)},
+		{"*/15 * * * *", sun.Add(time.Minute), sun.Add(15 * time.Minute)},
+		{"0 0 1 1 *", sun, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", sun, time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		s, err := ParseSchedule(c.Schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next, ok := s.Next(c.From); !ok || !next.Equal(c.Next) {
+			t.Errorf("%s: expected %s, got: %s", c.Schedule, c.Next, next)
+		}
+	}
+
+	s, _ := ParseSchedule("0 0 30 2 *")
+	if next, ok := s.Next(sun); ok {
+		t.Errorf("expected february 30th to never come, got: %s", next)
+	}
+
+	// a window that started the day before
+	m := Maintenance{Schedule: "0 23 * * *", Duration: 7200}
+	switch {
+	case !m.Active(time.Date(2026, time.October, 18, 0, 30, 0, 0, time.UTC)):
+		t.Errorf("expected the window to be active after midnight")
+	case m.Active(time.Date(2026, time.October, 18, 1, 0, 0, 0, time.UTC)):
+		t.Errorf("expected the window to be over")
+	case !m.Active(time.Date(2026, time.October, 18, 23, 0, 0, 0, time.UTC)):
+		t.Errorf("expected the window to be active when it starts")
+	}
+}
+
+func TestSilenceMatches(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 2, 30, 0, 0, time.UTC)
+	web := Transition{
+		Container: "web-1",
+		Image:     "myapp/web:1.2",
+		Labels:    map[string]string{ComposeServiceLabel: "web", "team": "frontend"},
+		Check:     "maxCpu",
+	}
+
+	cases := []struct {
+		Name    string
+		Silence Silence
+		Matches bool
+	}{
+		{"all", Silence{End: "2026-10-18T03:00:00Z"}, true},
+		{"glob", Silence{Container: "web-*", End: "2026-10-18T03:00:00Z"}, true},
+		{"other container", Silence{Container: "db", End: "2026-10-18T03:00:00Z"}, false},
+		{"check", Silence{Check: "maxcpu", End: "2026-10-18T03:00:00Z"}, true},
+		{"other check", Silence{Check: "running", End: "2026-10-18T03:00:00Z"}, false},
+		{"name regex", Silence{NameRegex: "^web-\\d+$", End: "2026-10-18T03:00:00Z"}, true},
+		{"label", Silence{Label: "team=frontend", End: "2026-10-18T03:00:00Z"}, true},
+		{"other label", Silence{Label: "team=backend", End: "2026-10-18T03:00:00Z"}, false},
+		{"image", Silence{Image: "myapp/web", End: "2026-10-18T03:00:00Z"}, true},
+		{"compose service", Silence{ComposeService: "web", End: "2026-10-18T03:00:00Z"}, true},
+		{"all selectors", Silence{ComposeService: "web", Container: "db",
+			End: "2026-10-18T03:00:00Z"}, false},
+		{"ended", Silence{End: "2026-10-18T02:30:00Z"}, false},
+		{"not started", Silence{Start: "2026-10-18T02:31:00Z", End: "2026-10-18T03:00:00Z"},
+			false},
+	}
+
+	for _, c := range cases {
+		if c.Silence.Matches(web, now) != c.Matches {
+			t.Errorf("%s: expected match %t", c.Name, c.Matches)
+		}
+	}
+
+	m := Maintenance{Label: "team", Schedule: "0 2 * * 0", Duration: 3600}
+	switch {
+	case !m.Matches(web, now):
+		t.Errorf("expected the maintenance window to be active")
+	case m.Matches(web, now.Add(31*time.Minute)):
+		t.Errorf("expected the maintenance window to be over")
+	case m.Matches(web, now.Add(-31*time.Minute)):
+		t.Errorf("expected the maintenance window to not have started")
+	}
+
+	v := Silence{Start: "2026-10-18T03:00:00Z", End: "2026-10-18T02:00:00Z"}
+	if err := v.Valid(); err == nil {
+		t.Errorf("expected an error for an end before the start")
+	}
+
+	v = Silence{NameRegex: "web-(", End: "2026-10-18T03:00:00Z"}
+	if err := v.Valid(); err == nil {
+		t.Errorf("expected an error for an invalid name regex")
+	}
+}
+
+func TestSilenceAPI(t *testing.T) {
+	defer func(s *SilenceStore, token string) {
+		silences, Config.SilenceToken = s, token
+	}(silences, Config.SilenceToken)
+	silences = &SilenceStore{silences: []Silence{}}
+	mux := NewServeMux()
+
+	add := func(auth string) *httptest.ResponseRecorder {
+		body := bytes.NewBufferString(`{"container": "web", "end": "2099-01-01T00:00:00Z"}`)
+		r := httptest.NewRequest("POST", "/api/silences", body)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		return rec
+	}
+
+	// without a token the silences can only be listed
+	Config.SilenceToken = ""
+	if rec := add("Bearer "); rec.Code != 403 {
+		t.Errorf("expected the API to be read only, got: %d", rec.Code)
+	}
+
+	Config.SilenceToken = "secret"
+	if rec := add("Bearer wrong"); rec.Code != 401 {
+		t.Errorf("expected a wrong token to be refused, got: %d", rec.Code)
+	}
+
+	rec := add("Bearer secret")
+	if rec.Code != 201 {
+		t.Fatalf("expected the silence to be added, got: %d %s", rec.Code, rec.Body)
+	}
+
+	var v Silence
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("DELETE", "/api/silences/"+v.ID, nil)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	if rec.Code != 401 {
+		t.Errorf("expected the delete without a token to be refused, got: %d", rec.Code)
+	}
+
+	r.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, r)
+	if rec.Code != 204 {
+		t.Errorf("expected the silence to be removed, got: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSilenceStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	path := filepath.Join(dir, "silences")
+	s := &SilenceStore{Path: path}
+	web := Transition{Container: "web", Check: "running"}
+
+	end := now.Add(time.Hour).Format(time.RFC3339)
+	v, err := s.Add(Silence{Container: "web", End: end}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second store reads the silences from the file like the daemon does
+	d := &SilenceStore{Path: path}
+	switch {
+	case !d.Silenced(web, now):
+		t.Errorf("expected the silence to be read from the file")
+	case d.Silenced(Transition{Container: "db"}, now):
+		t.Errorf("expected db to not be silenced")
+	}
+
+	if err := s.Remove(v.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(v.ID, now); !ErrContainsErr(err, ErrUnknownSilence) {
+		t.Errorf("expected an unknown silence error, got: %v", err)
+	}
+
+	// the mtime may not change within the resolution of the filesystem
+	os.Chtimes(path, now.Add(time.Minute), now.Add(time.Minute))
+	if d.Silenced(web, now) {
+		t.Errorf("expected the removed silence to be gone")
+	}
+}
+
+func TestEvaluateSilenced(t *testing.T) {
+	defer func(s *SilenceStore, a []Alerter) { silences, Config.Alerters = s, a }(silences,
+		Config.Alerters)
+
+	silences = &SilenceStore{Config: []Silence{
+		{Container: "web", End: time.Now().Add(time.Hour).Format(time.RFC3339)},
+	}}
+	r := &recordAlerter{alerts: make(chan *Alert, 1)}
+	Config.Alerters = []Alerter{r}
+
+	a := &Alert{Messages: []error{}}
+	a.Add(ErrUnknown, nil, "web", "")
+	a.TagFrom(0, Transition{Container: "web", Check: "running"})
+	a.Add(ErrUnknown, nil, "db", "")
+	a.TagFrom(1, Transition{Container: "db", Check: "running"})
+	a.Evaluate()
+
+	select {
+	case b := <-r.alerts:
+		if b.Len() != 1 || b.TransitionOf(0).Container != "db" {
+			t.Errorf("expected only the db message to be sent, got: %s", b.Dump())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the alert to be sent")
+	}
+}
+
+func TestSilenceRelease(t *testing.T) {
+	now := time.Now()
+	ended := now.Add(2 * time.Hour)
+	s := &SilenceStore{Config: []Silence{{End: now.Add(time.Hour).Format(time.RFC3339)}}}
+	none := &Alert{Messages: []error{}}
+
+	transition := func(container string, sev Severity) *Alert {
+		a := &Alert{Messages: []error{}}
+		a.AddSeverity(sev, ErrRunningCheckFail, nil, container, "")
+		a.TagFrom(0, Transition{Container: container, Check: "running"})
+		return a
+	}
+
+	// web fails within the silence
+	s.Hold(transition("web", SeverityCritical))
+
+	// the failure of api was sent before the silence and it recovers within it
+	s.Release(transition("api", SeverityCritical), now.Add(-time.Hour))
+	s.Hold(transition("api", SeverityOK))
+
+	// db fails and recovers within the silence
+	s.Hold(transition("db", SeverityCritical))
+	s.Hold(transition("db", SeverityOK))
+
+	// a transition that is sent replaces the held one
+	s.Hold(transition("cache", SeverityCritical))
+	s.Release(transition("cache", SeverityCritical), now)
+
+	if r := s.Release(none, now); r.Len() != 0 {
+		t.Errorf("expected nothing to be released within the silence, got: %s", r.Dump())
+	}
+
+	r := s.Release(none, ended)
+	switch {
+	case r.Len() != 2:
+		t.Fatalf("expected the api recovery and the web failure, got: %s", r.Dump())
+	case r.TransitionOf(0).Container != "api" || r.SeverityOf(0) != SeverityOK:
+		t.Errorf("expected the api recovery, got: %s", r.Messages[0])
+	case r.TransitionOf(1).Container != "web" || r.SeverityOf(1) != SeverityCritical:
+		t.Errorf("expected the web failure, got: %s", r.Messages[1])
+	}
+
+	if r := s.Release(none, ended); r.Len() != 0 {
+		t.Errorf("expected the transitions to be released once, got: %s", r.Dump())
+	}
+}
+
+func TestSilenceReleaseRestored(t *testing.T) {
+	now := time.Now()
+	defer func(s *SilenceStore) { silences = s }(silences)
+	silences = &SilenceStore{Config: []Silence{{End: now.Add(time.Hour).Format(time.RFC3339)}}}
+
+	// the cpu alert of web was sent before docker-alertd restarted
+	st := &StateStore{pending: State{"web": {"maxCpu": {AlertActive: true,
+		Severity: SeverityCritical}}}}
+	cnt := []AlertdContainer{NewAlertdContainer(Container{MaxCPU: uint64P(90)}, "web")}
+	st.Restore(cnt)
+
+	// it recovers within a silence
+	c := &cnt[0]
+	c.CheckUsage(c.CPUCheck, 10, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	if c.Alert.Len() != 1 || c.Alert.SeverityOf(0) != SeverityOK {
+		t.Fatalf("expected the restored alert to recover, got: %s", c.Alert.Dump())
+	}
+	silences.Hold(c.Alert)
+
+	r := silences.Release(&Alert{Messages: []error{}}, now.Add(2*time.Hour))
+	switch {
+	case r.Len() != 1:
+		t.Fatalf("expected the recovery to be released, got: %s", r.Dump())
+	case r.SeverityOf(0) != SeverityOK || r.TransitionOf(0).Check != "maxCpu":
+		t.Errorf("expected the maxCpu recovery, got: %s %+v", r.SeverityOf(0),
+			r.TransitionOf(0))
+	}
+}
+
+// recordAlerter is an alerter that passes the alerts it gets to a channel
+type recordAlerter struct {
+	alerts chan *Alert
+}
+
+func (r *recordAlerter) Name() string { return "record" }
+
+func (r *recordAlerter) Valid() error { return nil }
+
+func (r *recordAlerter) Alert(a *Alert) error {
+	r.alerts <- a
+	return nil
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// stateStore keeps the alert state of the checks across restarts, it is nil when no state
+// file is configured
+var stateStore *StateStore
+
+// CheckState is the saved alert state of a single check, Value is the last value of a metric
+// check when the state was written
+type CheckState struct {
+	AlertActive bool        `json:"alertActive"`
+	Severity    Severity    `json:"severity,omitempty"`
+	Flapping    bool        `json:"flapping,omitempty"`
+	Transitions []time.Time `json:"transitions,omitempty"`
+	Value       *uint64     `json:"value,omitempty"`
+	Changed     time.Time   `json:"changed"`
+}
+
+// State is the saved state of every check, by container name and then by check name
+type State map[string]map[string]CheckState
+
+// StateStore reads and writes the alert state to a file. The state is written whenever a
+// check changes state and is restored into the checkers when they are created after a
+// restart, so that active alerts are not sent again and recoveries are not forgotten.
+type StateStore struct {
+	Path string
+
+	// pending has the loaded state of the containers which have not been restored yet,
+	// written is the last state that was written without the values
+	pending State
+	written []byte
+}
+
+// MetricChecks returns the metric checks of the container by the name of their setting
+func (c *AlertdContainer) MetricChecks() map[string]*MetricCheck {
+	return map[string]*MetricCheck{
+		"maxCpu":           c.CPUCheck,
+		"maxMem":           c.MemCheck,
+		"maxMemPercent":    c.MemPercentCheck,
+		"minProcs":         c.PIDCheck,
+		"maxProcs":         c.MaxPIDCheck,
+		"maxNetRx":         c.NetRxCheck,
+		"maxNetTx":         c.NetTxCheck,
+		"maxNetErrors":     c.NetErrCheck,
+		"maxBlkioRead":     c.BlkioReadCheck,
+		"maxBlkioWrite":    c.BlkioWriteCheck,
+		"maxBlkioReadOps":  c.BlkioReadOpsCheck,
+		"maxBlkioWriteOps": c.BlkioWriteOpsCheck,
+		"maxRestarts":      c.RestartCheck,
+	}
+}
+
+// CheckName returns the name of the metric check of the container
+func (c *AlertdContainer) CheckName(m *MetricCheck) string {
+	for k, v := range c.MetricChecks() {
+		if v == m {
+			return k
+		}
+	}
+	return ""
+}
+
+// StaticChecks returns the static checks of the container by name
+func (c *AlertdContainer) StaticChecks() map[string]*StaticCheck {
+	return map[string]*StaticCheck{
+		"existence": c.ExistenceCheck,
+		"running":   c.RunningCheck,
+		"health":    c.HealthCheck,
+		"inspect":   c.InspectCheck,
+		"stats":     c.StatsCheck,
+	}
+}
+
+// CheckStates returns the state of all of the checks of the container
+func (c *AlertdContainer) CheckStates() map[string]CheckState {
+	states := map[string]CheckState{}
+	for k, m := range c.MetricChecks() {
+		if m != nil {
+			states[k] = CheckState{
+				AlertActive: m.AlertActive,
+				Severity:    m.Severity(),
+				Flapping:    m.Flapping,
+				Transitions: m.Transitions,
+				Value:       m.Value,
+				Changed:     m.Changed,
+			}
+		}
+	}
+
+	for k, s := range c.StaticChecks() {
+		if s != nil {
+			states[k] = CheckState{AlertActive: s.AlertActive, Changed: s.Changed}
+		}
+	}
+	return states
+}
+
+// RestoreStates sets the state of the checks of the container to the saved states
+func (c *AlertdContainer) RestoreStates(states map[string]CheckState) {
+	for k, m := range c.MetricChecks() {
+		if st, ok := states[k]; ok && m != nil {
+			m.SetSeverity(st.Severity)
+			m.AlertActive = st.AlertActive
+			m.Flapping = st.Flapping
+			m.Transitions = st.Transitions
+			m.Value = st.Value
+			m.Changed = st.Changed
+		}
+	}
+
+	for k, s := range c.StaticChecks() {
+		if st, ok := states[k]; ok && s != nil {
+			s.AlertActive = st.AlertActive
+			s.Changed = st.Changed
+		}
+	}
+}
+
+// LoadState returns a state store for the file, a file that does not exist yet is an empty
+// state
+func LoadState(path string) (*StateStore, error) {
+	s := &StateStore{Path: path, pending: State{}}
+
+	b, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return s, nil
+	case err != nil:
+		return nil, errors.Wrap(err, "reading state file")
+	}
+
+	if err := json.Unmarshal(b, &s.pending); err != nil {
+		return nil, errors.Wrap(err, "parsing state file")
+	}
+
+	log.Printf("loaded the alert state of %d containers from %s", len(s.pending), path)
+	return s, nil
+}
+
+// Restore sets the saved state of the containers which have not been restored yet, it is
+// called every loop so that containers found by selectors and discovery are restored too
+func (s *StateStore) Restore(cnt []AlertdContainer) {
+	if s == nil {
+		return
+	}
+
+	for i := range cnt {
+		if states, ok := s.pending[cnt[i].Name]; ok {
+			cnt[i].RestoreStates(states)
+			silences.Restored(&cnt[i])
+			delete(s.pending, cnt[i].Name)
+		}
+	}
+}
+
+// withoutValues returns the state without the metric values, which change on every sample
+func withoutValues(st State) State {
+	b := State{}
+	for name, checks := range st {
+		b[name] = map[string]CheckState{}
+		for k, v := range checks {
+			v.Value = nil
+			b[name][k] = v
+		}
+	}
+	return b
+}
+
+// Save writes the state of the containers to the file when any check changed state since
+// the last write. The containers that have not been restored yet are kept in the file.
+func (s *StateStore) Save(cnt []AlertdContainer) {
+	if s == nil {
+		return
+	}
+
+	st := State{}
+	for k, v := range s.pending {
+		st[k] = v
+	}
+	for i := range cnt {
+		st[cnt[i].Name] = cnt[i].CheckStates()
+	}
+
+	key, err := json.Marshal(withoutValues(st))
+	if err != nil || bytes.Equal(key, s.written) {
+		return
+	}
+
+	if err := s.write(st); err != nil {
+		log.Println(err)
+		return
+	}
+	s.written = key
+}
+
+// write replaces the state file, through a temporary file so that it is never left half
+// written
+func (s *StateStore) write(st State) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encoding state")
+	}
+
+	tmp := s.Path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return errors.Wrap(err, "writing state file")
+	}
+	return errors.Wrap(os.Rename(tmp, s.Path), "writing state file")
+}
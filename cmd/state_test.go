@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alertd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	conf := Container{MaxCPU: uint64P(90), ExpectedRunning: boolP(true)}
+	cnt := []AlertdContainer{NewAlertdContainer(conf, "test"),
+		NewAlertdContainer(conf, "other")}
+
+	s, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cnt[0].CheckUsage(cnt[0].CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	cnt[0].RunningCheck.AlertActive = true
+	s.Save(cnt)
+
+	// a restarted daemon only has the first container so far
+	s, err = LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := []AlertdContainer{NewAlertdContainer(conf, "test")}
+	s.Restore(restored)
+
+	switch {
+	case restored[0].CPUCheck.Severity() != SeverityCritical:
+		t.Errorf("expected the CPU check to be critical, got: %s",
+			restored[0].CPUCheck.Severity())
+	case restored[0].CPUCheck.Value == nil || *restored[0].CPUCheck.Value != 95:
+		t.Errorf("expected the last CPU value to be restored")
+	case !restored[0].RunningCheck.AlertActive:
+		t.Errorf("expected the running check to be active")
+	}
+
+	// the container which was not restored yet is kept in the file
+	restored[0].CPUCheck.SetSeverity(SeverityOK)
+	s.Save(restored)
+
+	s, err = LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.pending["other"]; !ok {
+		t.Errorf("expected the state of the other container to be kept")
+	}
+	if s.pending["test"]["maxCpu"].AlertActive {
+		t.Errorf("expected the CPU check recovery to be written")
+	}
+}
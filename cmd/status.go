@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// CheckStatus is the current state of a check, Changed is when the alert state of the check
+// last changed and is omitted when it has not changed since docker-alertd started
+type CheckStatus struct {
+	Name        string     `json:"name"`
+	AlertActive bool       `json:"alertActive"`
+	Severity    Severity   `json:"severity"`
+	Limit       *uint64    `json:"limit,omitempty"`
+	Warning     *uint64    `json:"warning,omitempty"`
+	Recover     *uint64    `json:"recover,omitempty"`
+	Value       *uint64    `json:"value,omitempty"`
+	Flapping    bool       `json:"flapping,omitempty"`
+	Changed     *time.Time `json:"changed,omitempty"`
+}
+
+// ContainerStatus is the current state of a monitored container and its checks
+type ContainerStatus struct {
+	Name       string        `json:"name"`
+	ID         string        `json:"id,omitempty"`
+	Selector   string        `json:"selector,omitempty"`
+	Discovered bool          `json:"discovered,omitempty"`
+	Checks     []CheckStatus `json:"checks"`
+}
+
+// timeP returns a pointer to the time, or nil for the zero time
+func timeP(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Status returns the state of the container and the checks that are enabled
+func (c *AlertdContainer) Status() ContainerStatus {
+	s := ContainerStatus{
+		Name:       c.Name,
+		ID:         c.ID,
+		Discovered: c.Discovered,
+		Checks:     []CheckStatus{},
+	}
+	if c.Selector != nil {
+		s.Selector = c.Selector.String()
+	}
+
+	statics := c.StaticChecks()
+	for _, k := range []string{"existence", "running", "health"} {
+		check := statics[k]
+		switch {
+		case check == nil:
+			continue
+		case k != "existence" && check.Expected == nil:
+			continue // the check is disabled
+		case k == "health" && !*check.Expected:
+			continue // the health check is only run when the container should be healthy
+		}
+
+		sev := SeverityOK
+		if check.AlertActive {
+			sev = SeverityCritical
+		}
+
+		s.Checks = append(s.Checks, CheckStatus{
+			Name:        k,
+			AlertActive: check.AlertActive,
+			Severity:    sev,
+			Changed:     timeP(check.Changed),
+		})
+	}
+
+	metrics := c.MetricChecks()
+	names := []string{}
+	for k, m := range metrics {
+		if m != nil && m.Limit != nil {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		m := metrics[k]
+		s.Checks = append(s.Checks, CheckStatus{
+			Name:        k,
+			AlertActive: m.AlertActive,
+			Severity:    m.Severity(),
+			Limit:       m.Limit,
+			Warning:     m.Warning,
+			Recover:     m.Recover,
+			Value:       m.Value,
+			Flapping:    m.Flapping,
+			Changed:     timeP(m.Changed),
+		})
+	}
+
+	return s
+}
+
+// Statuses returns the state of all of the containers
+func Statuses(cnt []AlertdContainer) []ContainerStatus {
+	s := []ContainerStatus{}
+	for i := range cnt {
+		s = append(s, cnt[i].Status())
+	}
+	return s
+}
+
+// health keeps track of the monitor loop for /healthz
+var health = &DaemonHealth{Started: time.Now()}
+
+// DaemonHealth is the health of docker-alertd itself, it is healthy as long as the monitor
+// loop keeps running
+type DaemonHealth struct {
+	mu       sync.Mutex
+	Started  time.Time
+	LastPoll time.Time
+	Interval time.Duration
+}
+
+// HealthStatus is the response of /healthz
+type HealthStatus struct {
+	Healthy  bool       `json:"healthy"`
+	Started  time.Time  `json:"started"`
+	LastPoll *time.Time `json:"lastPoll,omitempty"`
+}
+
+// Poll records that a monitor iteration finished at the time now
+func (h *DaemonHealth) Poll(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.LastPoll = now
+}
+
+// Status returns whether the monitor loop has finished an iteration recently, an iteration
+// can take the interval plus the time of the docker API calls so a minute of slack is given
+func (h *DaemonHealth) Status(now time.Time) HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last := h.LastPoll
+	if last.IsZero() {
+		last = h.Started
+	}
+
+	return HealthStatus{
+		Healthy:  now.Sub(last) < 3*h.Interval+time.Minute,
+		Started:  h.Started,
+		LastPoll: timeP(h.LastPoll),
+	}
+}
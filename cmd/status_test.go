@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusAPI(t *testing.T) {
+	c := NewAlertdContainer(Container{MaxCPU: uint64P(90), ExpectedRunning: boolP(true)},
+		"test")
+	c.CheckUsage(c.CPUCheck, 95, ErrCPUCheckFail, ErrCPUCheckRecovered, "CPU")
+	defer func(s *Snapshot) { snapshot = s }(snapshot)
+	snapshot = &Snapshot{}
+	snapshot.Update([]AlertdContainer{c})
+
+	// the state is published so a poll that holds checkMu does not block the API
+	checkMu.Lock()
+	defer checkMu.Unlock()
+	mux := NewServeMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/containers/test", nil))
+
+	var s ContainerStatus
+	if err := json.NewDecoder(rec.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := map[string]CheckStatus{}
+	for _, v := range s.Checks {
+		checks[v.Name] = v
+	}
+
+	cpu := checks["maxCpu"]
+	switch {
+	case len(s.Checks) != 3:
+		t.Errorf("expected the existence, running and maxCpu checks, got: %+v", s.Checks)
+	case !cpu.AlertActive || cpu.Severity != SeverityCritical || cpu.Changed == nil:
+		t.Errorf("expected the maxCpu alert to be active, got: %+v", cpu)
+	case cpu.Value == nil || *cpu.Value != 95 || cpu.Limit == nil || *cpu.Limit != 90:
+		t.Errorf("expected the maxCpu value and limit, got: %+v", cpu)
+	case checks["running"].AlertActive || checks["running"].Changed != nil:
+		t.Errorf("expected the running check to be inactive, got: %+v", checks["running"])
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/containers/other", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected an unknown container to be not found, got: %d", rec.Code)
+	}
+}
+
+func TestDaemonHealth(t *testing.T) {
+	now := time.Now()
+	h := &DaemonHealth{Started: now, Interval: time.Second}
+
+	if !h.Status(now.Add(30 * time.Second)).Healthy {
+		t.Errorf("expected the daemon to be healthy while it starts")
+	}
+	if h.Status(now.Add(2 * time.Minute)).Healthy {
+		t.Errorf("expected the daemon to be unhealthy when it never polled")
+	}
+
+	h.Poll(now.Add(2 * time.Minute))
+	if !h.Status(now.Add(2 * time.Minute)).Healthy {
+		t.Errorf("expected the daemon to be healthy after a poll")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,11 +15,74 @@ type Evaluator interface {
 	Evaluate()
 }
 
+// Severity is the level of an alert message, OK is used for the messages of checks that
+// have recovered
+type Severity int
+
+// the severity levels, in increasing order
+const (
+	SeverityOK Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+// String returns the name of the severity that is shown in the alerts
+func (s Severity) String() string {
+	switch s {
+	case SeverityOK:
+		return "OK"
+	case SeverityWarning:
+		return "WARNING"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// MarshalText encodes the severity as its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the name of a severity
+func (s *Severity) UnmarshalText(b []byte) error {
+	for _, v := range []Severity{SeverityOK, SeverityWarning, SeverityCritical} {
+		if strings.EqualFold(string(b), v.String()) {
+			*s = v
+			return nil
+		}
+	}
+	return errors.Errorf("unknown severity: %s", b)
+}
+
 // Alert is the struct that stores information about alerts and its methods satisfy the
 // Alerter interface
 type Alert struct {
 	Messages         []error
 	SubjectAddendums []string
+
+	// Route has the names of the alerters that messages added to this alert are sent to,
+	// Routes has the route of every message. An empty route sends to all of the alerters.
+	Route  []string
+	Routes [][]string
+
+	// Severities has the severity of every message
+	Severities []Severity
+
+	// Transitions has the check change that every message was sent for
+	Transitions []Transition
+}
+
+// Transition is the change of a check that an alert message is sent for, Value and Limit
+// are set for the metric checks. ID, Image and Labels are set once the container was
+// inspected.
+type Transition struct {
+	Container string
+	ID        string
+	Image     string
+	Labels    map[string]string
+	Check     string
+	Value     *uint64
+	Limit     *uint64
 }
 
 // ShouldSend returns true if there is an alert message to be sent
@@ -26,10 +90,25 @@ func (a *Alert) ShouldSend() bool {
 	return len(a.Messages) > 0
 }
 
-// Evaluate will check if error should be sent and then trigger it if necessary
+// Evaluate will check if error should be sent and then trigger it if necessary, the
+// messages that are silenced are logged and recorded but not sent until the silence ends
 func (a *Alert) Evaluate() {
-	if a.ShouldSend() {
-		a.Send(Config.Alerters)
+	now := time.Now()
+	silenced := make([]bool, a.Len())
+	for i := range a.Messages {
+		silenced[i] = silences.Silenced(a.TransitionOf(i), now)
+	}
+
+	if s := a.Filter(func(i int) bool { return silenced[i] }); s.ShouldSend() {
+		s.Silence(now)
+		silences.Hold(s)
+	}
+
+	// the transitions that were held back by silences which have ended are sent too
+	s := a.Filter(func(i int) bool { return !silenced[i] })
+	s.Concat(silences.Release(s, now))
+	if s.ShouldSend() {
+		s.Send(Config.Alerters)
 	}
 }
 
@@ -38,8 +117,13 @@ func (a *Alert) Len() int {
 	return len(a.Messages)
 }
 
-// Add should take in an error and wrap it
+// Add should take in an error and wrap it, the message is critical
 func (a *Alert) Add(e1, e2 error, s, subAddendum string) {
+	a.AddSeverity(SeverityCritical, e1, e2, s, subAddendum)
+}
+
+// AddSeverity is Add for a message with the given severity
+func (a *Alert) AddSeverity(sev Severity, e1, e2 error, s, subAddendum string) {
 
 	a.SubjectAddendums = append(a.SubjectAddendums, subAddendum)
 
@@ -50,13 +134,27 @@ func (a *Alert) Add(e1, e2 error, s, subAddendum string) {
 
 	err := errors.Wrap(e, s)
 	a.Messages = append(a.Messages, err)
+	a.Routes = append(a.Routes, a.Route)
+	a.Severities = append(a.Severities, sev)
+	a.Transitions = append(a.Transitions, Transition{})
+}
+
+// TagFrom sets the transition of the messages from index i on, checks call it with the
+// length of the alert from before they added their messages
+func (a *Alert) TagFrom(i int, t Transition) {
+	for ; i < len(a.Transitions); i++ {
+		a.Transitions[i] = t
+	}
 }
 
 // Concat will concat different alerts from containers together into one
 func (a *Alert) Concat(b ...*Alert) {
 	for _, v := range b {
-		for _, msg := range v.Messages {
+		for i, msg := range v.Messages {
 			a.Messages = append(a.Messages, msg)
+			a.Routes = append(a.Routes, v.RouteOf(i))
+			a.Severities = append(a.Severities, v.SeverityOf(i))
+			a.Transitions = append(a.Transitions, v.TransitionOf(i))
 		}
 
 		for _, addendum := range v.SubjectAddendums {
@@ -68,8 +166,8 @@ func (a *Alert) Concat(b ...*Alert) {
 // Log prints the alert to the log
 func (a *Alert) Log() {
 	log.Println("ALERT:")
-	for _, msg := range a.Messages {
-		log.Println(msg)
+	for i, msg := range a.Messages {
+		log.Printf("[%s] %s", a.SeverityOf(i), msg)
 	}
 }
 
@@ -77,13 +175,92 @@ func (a *Alert) Log() {
 func (a *Alert) Clear() {
 	a.Messages = []error{}
 	a.SubjectAddendums = []string{}
+	a.Routes = [][]string{}
+	a.Severities = []Severity{}
+	a.Transitions = []Transition{}
+}
+
+// RouteOf returns the route of the message at index i
+func (a *Alert) RouteOf(i int) []string {
+	if i >= len(a.Routes) {
+		return nil
+	}
+	return a.Routes[i]
+}
+
+// SeverityOf returns the severity of the message at index i, messages added without a
+// severity are critical
+func (a *Alert) SeverityOf(i int) Severity {
+	if i >= len(a.Severities) {
+		return SeverityCritical
+	}
+	return a.Severities[i]
+}
+
+// TransitionOf returns the transition of the message at index i
+func (a *Alert) TransitionOf(i int) Transition {
+	if i >= len(a.Transitions) {
+		return Transition{}
+	}
+	return a.Transitions[i]
+}
+
+// RoutedTo returns true if the message at index i is sent to the alerter
+func (a *Alert) RoutedTo(i int, name string) bool {
+	route := a.RouteOf(i)
+	return len(route) == 0 || containsString(route, name)
+}
+
+// Severity returns the highest severity of the messages in the alert
+func (a *Alert) Severity() Severity {
+	sev := SeverityOK
+	for i := range a.Messages {
+		if a.SeverityOf(i) > sev {
+			sev = a.SeverityOf(i)
+		}
+	}
+	return sev
+}
+
+// For returns a copy of the alert with only the messages that are routed to the alerter
+func (a *Alert) For(name string) *Alert {
+	return a.Filter(func(i int) bool { return a.RoutedTo(i, name) })
+}
+
+// Filter returns a copy of the alert with only the messages that keep returns true for
+func (a *Alert) Filter(keep func(i int) bool) *Alert {
+	b := &Alert{Messages: []error{}}
+	for i, msg := range a.Messages {
+		if !keep(i) {
+			continue
+		}
+
+		b.Messages = append(b.Messages, msg)
+		b.Routes = append(b.Routes, a.RouteOf(i))
+		b.Severities = append(b.Severities, a.SeverityOf(i))
+		b.Transitions = append(b.Transitions, a.TransitionOf(i))
+		if i < len(a.SubjectAddendums) {
+			b.SubjectAddendums = append(b.SubjectAddendums, a.SubjectAddendums[i])
+		}
+	}
+	return b
+}
+
+// containsString returns true if the string is in the slice
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // Dump takes the slice of alerts and dumps them to a single string
 func (a *Alert) Dump() string {
 	s := ""
-	for _, v := range a.Messages {
-		s += fmt.Sprintf("%s\n\n", v.Error())
+	for i, v := range a.Messages {
+		s += fmt.Sprintf("[%s] %s\n\n", a.SeverityOf(i), v.Error())
 	}
 	return s
 }
@@ -95,8 +272,8 @@ func (a *Alert) Dump() string {
 // 		[alertName]:
 // 		Error: [errString]
 func (a *Alert) DumpEmail() (s string) {
-	for _, e := range a.Messages {
-		errString := e.Error()
+	for i, e := range a.Messages {
+		errString := fmt.Sprintf("[%s] %s", a.SeverityOf(i), e.Error())
 		splitErr := strings.SplitN(errString, ":", 3)
 
 		for _, v := range splitErr {
@@ -108,15 +285,49 @@ func (a *Alert) DumpEmail() (s string) {
 	return s
 }
 
-// Send is for sending out alerts to syslog and to alerts that are active in conf
+// Silence logs the silenced messages and records them in the history without sending them
+func (a *Alert) Silence(now time.Time) {
+	log.Println("SILENCED:")
+	for i, msg := range a.Messages {
+		log.Printf("[%s] %s", a.SeverityOf(i), msg)
+	}
+
+	records := a.HistoryRecords(now)
+	for i := range records {
+		records[i].Silenced = true
+	}
+	dashboard.AddHistory(records)
+	historyStore.Record(records, nil, 0)
+}
+
+// Send is for sending out alerts to syslog and to alerts that are active in conf, the
+// messages are recorded in the history once all of the alerters are done
 func (a *Alert) Send(b []Alerter) {
 	a.Log()
+
+	records := a.HistoryRecords(time.Now())
+	dashboard.AddHistory(records)
+	sent := make(chan string, len(b))
+	n := 0
+
 	for i := range b {
-		go func(c Alerter) {
-			err := c.Alert(a)
+		routed := a.For(b[i].Name())
+		if !routed.ShouldSend() {
+			continue
+		}
+
+		n++
+		go func(c Alerter, r *Alert) {
+			err := c.Alert(r)
+			AlertSent(c.Name(), err)
 			if err != nil {
 				log.Println(err)
+				sent <- ""
+				return
 			}
-		}(b[i])
+			sent <- c.Name()
+		}(b[i], routed)
 	}
+
+	historyStore.Record(records, sent, n)
 }
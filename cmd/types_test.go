@@ -63,6 +63,85 @@ func TestConfigValidate(t *testing.T) {
 			},
 			ExpectedErr: ErrEmailNoFrom,
 		},
+		{
+			Name: "config routed to named webhooks passes",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name:     "some_container",
+						Alerters: []string{"webhook", "webhook:incidents"},
+					},
+				},
+				Webhook:  Webhook{URL: "https://example.com/alerts"},
+				Webhooks: []Webhook{{ID: "incidents", URL: "https://example.com/incidents"}},
+			},
+			ExpectedErr: nil,
+		},
+		{
+			Name: "config with a webhook without an id fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name: "some_container",
+					},
+				},
+				Webhooks: []Webhook{{URL: "https://example.com/incidents"}},
+			},
+			ExpectedErr: ErrWebhookID,
+		},
+		{
+			Name: "config routed to an unknown webhook fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name:     "some_container",
+						Alerters: []string{"webhook:other"},
+					},
+				},
+				Webhooks: []Webhook{{ID: "incidents", URL: "https://example.com/incidents"}},
+			},
+			ExpectedErr: ErrUnknownAlerter,
+		},
+		{
+			Name: "config with invalid memory unit fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name:    "some_container",
+						MaxMem:  uint64P(20),
+						MemUnit: "KB",
+					},
+				},
+			},
+			ExpectedErr: ErrMemUnit,
+		},
+		{
+			Name: "config with memory percent over 100 fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name:          "some_container",
+						MaxMemPercent: uint64P(120),
+					},
+				},
+			},
+			ExpectedErr: ErrMemPercent,
+		},
+		{
+			Name: "config with recover level over the warning level fails",
+			Config: &Conf{
+				Containers: []Container{
+					Container{
+						Name:   "some_container",
+						MaxCPU: uint64P(90),
+						Checks: map[string]CheckSettings{
+							"maxcpu": {Warning: uint64P(70), Recover: uint64P(80)},
+						},
+					},
+				},
+			},
+			ExpectedErr: ErrRecoverLimit,
+		},
 	}
 
 	for _, test := range tests {
@@ -73,3 +152,24 @@ func TestConfigValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestAlertFor(t *testing.T) {
+	a := &Alert{Messages: []error{}}
+
+	slackOnly := &Alert{Messages: []error{}, Route: []string{"slack"}}
+	slackOnly.Add(ErrCPUCheckFail, nil, "routed", ErrCPUCheckFail.Error())
+
+	everyone := &Alert{Messages: []error{}}
+	everyone.Add(ErrMemCheckFail, nil, "everyone", ErrMemCheckFail.Error())
+
+	a.Concat(slackOnly, everyone)
+
+	if l := a.For("slack").Len(); l != 2 {
+		t.Errorf("expected 2 messages for slack, got %d", l)
+	}
+
+	email := a.For("email")
+	if email.Len() != 1 || !CheckHasErr(email.Messages, ErrMemCheckFail) {
+		t.Errorf("expected only the memory alert for email, got %v", email.Messages)
+	}
+}